@@ -22,14 +22,20 @@ $(document).ready(function() {
   xpGoalInputMask();
   levelGoalInputMask();
 
-  $("#username-field").inputmask('Regex', {
+  $(".username-field").inputmask('Regex', {
     regex: "[a-zA-Z0-9-_ ]{1,12}",
     placeholder: "",
     greedy: false
   });
 
-  $("#goal-dropdown > .item").click(function(e) {
-    var goalField = $('#tooltip-skill-goal');
+  $(".number-field").inputmask({
+    mask: "9{1,9}",
+    placeholder: "",
+    greedy: false
+  });
+
+  $(".goal-dropdown > .item").click(function(e) {
+    var goalField = $(e.target).closest('.input').find('.goal-field');
     var newItem = e.target.outerText;
     var goalClass;
     var refreshFunction = null;
@@ -40,7 +46,7 @@ $(document).ready(function() {
       goalClass = 'tooltip-sig-level-goal';
       refreshFunction = levelGoalInputMask;
     }
-    goalField.attr('class', goalClass);
+    goalField.attr('class', 'goal-field ' + goalClass);
     goalField.val('');
     if (refreshFunction != null) {
       refreshFunction.call();
@@ -3,15 +3,15 @@
 {% block title %}RuneScape Signatures{% endblock %}
 {% block body %}
 
-{% macro skill_dropdown(skills) %}
+{% macro skill_dropdown(name, skills) %}
 <div class="ui selection dropdown" tabindex="0" style="width: 100%; border-radius: 0;">
-  <select name="skill">
+  <select name="{{ name }}">
     {% for skill in skills %}
     <option value="{{ skill|lower }}">{{ skill }}</option>
     {% endfor %}
   </select>
   <i class="dropdown icon"></i>
-  <div class="text">Attack</div>
+  <div class="text">{{ skills.0 }}</div>
   <div class="menu transition hidden" tabindex="0">
     {% for skill in skills %}
     <div class="item" data-value="{{ skill|lower }}">{{ skill }}</div>
@@ -19,98 +19,94 @@
   </div>
 </div>
 {% endmacro %}
+{% macro select_dropdown(name, options, selected) %}
+<div class="ui selection dropdown" tabindex="0" style="width: 100%; border-radius: 0;">
+  <select name="{{ name }}">
+    {% for option in options %}
+    <option value="{{ option }}"{% if option == selected %} selected{% endif %}>{{ option|capfirst }}</option>
+    {% endfor %}
+  </select>
+  <i class="dropdown icon"></i>
+  <div class="text">{{ selected|default:options.0|capfirst }}</div>
+  <div class="menu transition hidden" tabindex="0">
+    {% for option in options %}
+    <div class="item" data-value="{{ option }}">{{ option|capfirst }}</div>
+    {% endfor %}
+  </div>
+</div>
+{% endmacro %}
+{% macro goal_input(name, default) %}
+<input class="goal-field tooltip-sig-level-goal" type="text" name="{{ name }}" placeholder="{{ default }}" style="border-radius: 0;">
+<div class="ui dropdown label" tabindex="0" style="border-radius: 0;">
+  <div class="text">Level</div>
+  <i class="dropdown icon"></i>
+  <div class="goal-dropdown menu transition hidden" tabindex="-1">
+    <div class="item active selected">Level</div>
+    <div class="item">Experience</div>
+  </div>
+</div>
+{% endmacro %}
 <div class="ui container">
-  <div class="ui two column grid">
-    <!-- Tooltip goal -->
+  {% for entries in games %}
+  <h2 class="ui dividing header">{{ entries.Game.Title }}</h2>
+  <div class="ui two column stackable grid">
+    {% for generator in entries.Generators %}
     <div class="column">
-      <div class="ui raised segment three column grid">
-        <div class="ten wide column">
-          <h3>Skill interface tooltip</h3>
-          <form id="generator" class="ui large form" action="/tooltip/create" method="POST">
-            <div class="field">
-              <div class="ui fluid labeled small input">
-                <div class="ui label">Username:</div>
-                <input id="username-field" type="text" name="username">
-              </div>
-            </div>
-            <div class="field">
-              <div class="ui fluid labeled small input">
-                <div class="ui label">Skill:</div>
-                {{ skill_dropdown(skills) }}
+      <div class="ui raised segment {% if generator.Example %}three{% else %}one{% endif %} column grid">
+        <div class="{% if generator.Example %}ten wide {% endif %}column">
+          <h3>{{ generator.Title }}</h3>
+          <p>{{ generator.Description }}</p>
+          <form class="ui large form" action="{{ generator.FormUrl }}" method="POST">
+            {% for param in generator.Params %}
+            {% if param.Type == "skill_goals" %}
+            {% for row in param.Rows() %}
+            <div class="ui two column grid">
+              <div class="six wide column">
+                <div class="field">
+                  <div class="ui fluid labeled small input">
+                    <div class="ui label">Skill:</div>
+                    {{ skill_dropdown(param.Name|add:"_skill_"|add:row, entries.Game.SkillNames) }}
+                  </div>
+                </div>
               </div>
-            </div>
-            <div class="field">
-              <div class="ui fluid labeled small input">
-                <div class="ui label">Goal:</div>
-                <input id="tooltip-skill-goal" class="tooltip-sig-level-goal" type="text" name="goal" style="border-radius: 0;">
-                <div class="ui dropdown label" tabindex="0" style="border-radius: 0;">
-                  <div class="text">Level</div>
-                  <i class="dropdown icon"></i>
-                  <div id="goal-dropdown" class="menu transition hidden" tabindex="-1">
-                    <div class="item active selected">Level</div>
-                    <div class="item">Experience</div>
+              <div class="ten wide column">
+                <div class="field">
+                  <div class="ui fluid labeled small input">
+                    <div class="ui label">Goal:</div>
+                    {{ goal_input(param.Name|add:"_goal_"|add:row, "") }}
                   </div>
                 </div>
               </div>
             </div>
-            {% if has_aes %}
+            {% endfor %}
+            {% elif param.Type == "bool" %}
             <div class="field">
               <div class="ui checkbox">
-                <input type="checkbox" name="hide">
-                <label>Hide username</label>
+                <input type="checkbox" name="{{ param.Name }}"{% if param.Default == "true" %} checked{% endif %}>
+                <label>{{ param.Label }}</label>
               </div>
             </div>
-            {% endif %}
+            {% else %}
             <div class="field">
-              <input type="submit" name="submit" class="ui button" value="Create">
-            </div>
-          </form>
-        </div>
-        <div class="right floated six wide column">
-          <img class="ui top aligned image" src="/assets/img/box_example.png" alt="Example" />
-        </div>
-      </div>
-    </div>
-    <!-- Multiple goals -->
-    <div class="column">
-      <div class="ui raised segment one column grid">
-        <div class="column">
-          <h3>Multiple skill goals in one</h3>
-		  <form id="generator" class="ui large form" action="/multi/create" method="POST">
-            <div class="field">
-              <div class="ui fluid labeled small input">
-                <div class="ui label">Username:</div>
-                <input id="username-field" type="text" name="username">
+              <div class="ui fluid labeled small input" title="{{ param.Description }}">
+                <div class="ui label">{{ param.Label }}:</div>
+                {% if param.Type == "skill" %}
+                {{ skill_dropdown(param.Name, entries.Game.SkillNames) }}
+                {% elif param.Type == "goal" %}
+                {{ goal_input(param.Name, param.Default) }}
+                {% elif param.Type == "select" %}
+                {{ select_dropdown(param.Name, param.Options, param.Default) }}
+                {% elif param.Type == "username" %}
+                <input class="username-field" type="text" name="{{ param.Name }}">
+                {% elif param.Type == "text" %}
+                <input type="text" name="{{ param.Name }}" placeholder="{{ param.Default }}">
+                {% else %}
+                <input class="number-field" type="text" name="{{ param.Name }}" placeholder="{{ param.Default }}">
+                {% endif %}
               </div>
             </div>
-			<div id="skillgoals">
-				<div id="multiskill" class="ui two column grid">
-					<div class="six wide column">
-						<div class="field">
-							<div class="ui fluid labeled small input">
-								<div class="ui label">Skill:</div>
-								{{ skill_dropdown(skills) }}
-							</div>
-						</div>
-					</div>
-					<div class="ten wide column">
-						<div class="field">
-							<div class="ui fluid labeled small input">
-								<div class="ui label">Goal:</div>
-								<input id="tooltip-skill-goal" class="tooltip-sig-level-goal" type="text" name="goal" style="border-radius: 0;">
-								<div class="ui dropdown label" tabindex="0" style="border-radius: 0;">
-								<div class="text">Level</div>
-								<i class="dropdown icon"></i>
-								<div id="goal-dropdown" class="menu transition hidden" tabindex="-1">
-									<div class="item active selected">Level</div>
-									<div class="item">Experience</div>
-								</div>
-								</div>
-							</div>
-						</div>
-					</div>
-				</div>
-			</div>
+            {% endif %}
+            {% endfor %}
             {% if has_aes %}
             <div class="field">
               <div class="ui checkbox">
@@ -124,9 +120,16 @@
             </div>
           </form>
         </div>
+        {% if generator.Example %}
+        <div class="right floated six wide column">
+          <img class="ui top aligned image" src="{{ generator.Example }}" alt="Example" />
+        </div>
+        {% endif %}
       </div>
     </div>
+    {% endfor %}
   </div>
+  {% endfor %}
 </div>
 
 <script type="text/javascript" src="//cdnjs.cloudflare.com/ajax/libs/jquery/2.2.0/jquery.js"></script>
@@ -6,6 +6,13 @@
 <div class="ui container">
   <div class="ui one column centered grid">
     <div class="twelve wide column">
+      {% if error %}
+      <div class="ui raised segment">
+        <h3>Could not create the signature</h3>
+        <div class="ui negative message">{{ error }}</div>
+        <a href="/" class="ui primary button">Back to home</a>
+      </div>
+      {% else %}
       <div class="ui raised segment two column centered internally celled grid">
         <div class="eight wide column">
           <h3>Result</h3>
@@ -37,6 +44,7 @@
           <img class="ui centered image" src="{{ base_url }}{{ url }}" alt="Example" />
         </div>
       </div>
+      {% endif %}
     </div>
   </div>
 </div>
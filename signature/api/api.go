@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/cubeee/go-sig/signature/generators/rs3"
+	"github.com/cubeee/go-sig/signature/util"
+	"github.com/zenazn/goji/web"
+)
+
+// GoalResponse is the progress of a player towards a skill goal
+type GoalResponse struct {
+	Username  string `json:"username"`
+	Skill     string `json:"skill"`
+	Level     int    `json:"level"`
+	Xp        int    `json:"xp"`
+	Goal      int    `json:"goal"`
+	GoalType  string `json:"goal_type"`
+	GoalLevel int    `json:"goal_level"`
+	GoalXp    int    `json:"goal_xp"`
+	Remaining int    `json:"remaining"`
+	Percent   int    `json:"percent"`
+	// XpPerDay is the recent rate read from the recorded history, it is left out when there isn't enough history
+	XpPerDay   float64 `json:"xp_per_day,omitempty"`
+	EtaSeconds int64   `json:"eta_seconds,omitempty"`
+	Eta        string  `json:"eta,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// GoalHandler serves the progress towards a goal as JSON. It accepts the same urls and query parameters as
+// the box generator, the eta is included whenever a rate is known.
+func GoalHandler(generator *rs3.BoxGoalGenerator) func(web.C, http.ResponseWriter, *http.Request) {
+	return func(c web.C, writer http.ResponseWriter, request *http.Request) {
+		req, err := generator.ParseRequest(c, request)
+		if err == nil {
+			err = req.Validate()
+		}
+		if err != nil {
+			writeJSON(writer, http.StatusBadRequest, errorResponse{Error: err.Error()})
+			return
+		}
+
+		player := util.Player{Name: req.Username, Game: generator.Game.Mode, Table: req.Table}
+		stats, err := generator.Stats.GetStats(player)
+		if err != nil {
+			status, message := util.ErrorStatus(err)
+			writeJSON(writer, status, errorResponse{Error: message})
+			return
+		}
+		progress := util.Progress(util.GetStatBySkill(stats, req.Skill), req.Goal, req.GoalType, req.Virtual)
+
+		goalType := "level"
+		if req.GoalType == util.GoalXP {
+			goalType = "xp"
+		}
+		response := GoalResponse{
+			Username:  req.Username,
+			Skill:     req.Skill.Name,
+			Level:     progress.CurrentLevel,
+			Xp:        progress.CurrentXP,
+			Goal:      req.Goal,
+			GoalType:  goalType,
+			GoalLevel: progress.GoalLevel,
+			GoalXp:    progress.GoalXP,
+			Remaining: progress.Remainder,
+			Percent:   progress.Percent,
+		}
+		if generator.History != nil {
+			if perDay, ok, err := generator.History.XPPerDay(player, req.Skill, time.Now()); err == nil && ok {
+				response.XpPerDay = perDay
+			}
+		}
+		if progress.Remainder > 0 {
+			if eta, ok := generator.History.ETA(player, req.Skill, progress.Remainder, req.Rate); ok {
+				response.EtaSeconds = int64(eta.Seconds())
+				response.Eta = util.FormatDuration(eta)
+			}
+		}
+		writeJSON(writer, http.StatusOK, response)
+	}
+}
+
+func writeJSON(writer http.ResponseWriter, status int, value interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(value)
+}
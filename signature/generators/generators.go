@@ -6,16 +6,68 @@ import (
 	"github.com/cubeee/go-sig/signature/util"
 )
 
+// Request is implemented by the request type of every generator
+type Request interface {
+	// Validate makes sure the parsed values are within bounds
+	Validate() error
+	// Hash identifies the signature the request renders, the generator name is added to it when stored
+	Hash() string
+}
+
+// AnimatedRequest is implemented by requests that can render an animated GIF instead of a still image
+type AnimatedRequest interface {
+	Animated() bool
+}
+
+// Generator creates signatures from its own request type R
+type Generator[R Request] interface {
+	Name() string
+	Url() string
+	Metadata() Metadata
+	ParseRequest(c web.C, r *http.Request) (R, error)
+	CreateSignature(req R) (util.Signature, error)
+	CreateErrorSignature(message string) (util.Signature, error)
+}
+
+// BaseGenerator is a generator with its request type erased so generators can be registered side by side
 type BaseGenerator interface {
 	Name() string
 	Url() string
-	FormUrl() string
-	CreateSignature(req util.ParsedSignatureRequest) (util.Signature, error)
-	CreateHash(req util.ParsedSignatureRequest) string
-	ParseSignatureRequest(c web.C, r *http.Request) (util.ParsedSignatureRequest, error)
-	HandleForm(c web.C, writer http.ResponseWriter, request *http.Request)
+	Metadata() Metadata
+	// ParseSignatureRequest parses and validates the request
+	ParseSignatureRequest(c web.C, r *http.Request) (Request, error)
+	// CreateSignature only accepts requests returned by ParseSignatureRequest of the same generator
+	CreateSignature(req Request) (util.Signature, error)
+	CreateErrorSignature(message string) (util.Signature, error)
+}
+
+type SignatureRequest struct {
+	Req  Request
+	Hash string
+	// Format is the file format the signature is saved in
+	Format util.ImageFormat
+}
+
+// Wrap erases the request type of the generator
+func Wrap[R Request](generator Generator[R]) BaseGenerator {
+	return wrappedGenerator[R]{generator}
+}
+
+type wrappedGenerator[R Request] struct {
+	Generator[R]
+}
+
+func (w wrappedGenerator[R]) ParseSignatureRequest(c web.C, r *http.Request) (Request, error) {
+	req, err := w.Generator.ParseRequest(c, r)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Validate(); err != nil {
+		return nil, err
+	}
+	return req, nil
 }
 
-type Generator struct {
-	BaseGenerator
+func (w wrappedGenerator[R]) CreateSignature(req Request) (util.Signature, error) {
+	return w.Generator.CreateSignature(req.(R))
 }
@@ -0,0 +1,274 @@
+package generators
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/cubeee/go-sig/signature/util"
+	"github.com/zenazn/goji/web"
+)
+
+// Parameter types, they decide how a parameter is shown on the front page form
+const (
+	ParamUsername   = "username"
+	ParamSkill      = "skill"
+	ParamGoal       = "goal"
+	ParamNumber     = "number"
+	ParamText       = "text"
+	ParamSelect     = "select"
+	ParamBool       = "bool"
+	ParamSkillGoals = "skill_goals"
+)
+
+// Param describes a single parameter of a generator. Parameters whose name appears in the generator's
+// url pattern are path parameters, the rest are added to the query string.
+type Param struct {
+	Name        string
+	Type        string
+	Label       string
+	Description string
+	Min         int
+	// Max is the upper bound of a number or goal, or the number of rows shown for skill goals
+	Max     int
+	Default string
+	Options []string
+}
+
+// Rows returns the row indices of a skill goals parameter for the form template
+func (p Param) Rows() []int {
+	rows := make([]int, p.Max)
+	for i := range rows {
+		rows[i] = i
+	}
+	return rows
+}
+
+// Metadata describes a generator for the front page and the form handler
+type Metadata struct {
+	Title       string
+	Description string
+	Game        *util.Game
+	Params      []Param
+	// Example is the url of an example image
+	Example string
+}
+
+// HasParam tells whether the generator accepts the parameter
+func (m Metadata) HasParam(name string) bool {
+	for _, param := range m.Params {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Entry is a registered generator along with its routes
+type Entry struct {
+	Name    string
+	Url     string
+	FormUrl string
+	Metadata
+}
+
+// GameEntries holds the registered generators of a game
+type GameEntries struct {
+	Game       *util.Game
+	Generators []Entry
+}
+
+// Registry keeps the registered generators in registration order
+type Registry struct {
+	generators []BaseGenerator
+}
+
+func (r *Registry) Register(generator BaseGenerator) {
+	r.generators = append(r.generators, generator)
+}
+
+func (r *Registry) Generators() []BaseGenerator {
+	return r.generators
+}
+
+// Games groups the registered generators by game, in the order the games are defined
+func (r *Registry) Games() []GameEntries {
+	var games []GameEntries
+	for mode := util.RS3; int(mode) < len(util.Games); mode++ {
+		entries := GameEntries{Game: util.Games[mode]}
+		for _, generator := range r.generators {
+			meta := generator.Metadata()
+			if meta.Game != entries.Game {
+				continue
+			}
+			entries.Generators = append(entries.Generators, Entry{
+				Name:     generator.Name(),
+				Url:      generator.Url(),
+				FormUrl:  FormUrl(generator),
+				Metadata: meta,
+			})
+		}
+		if len(entries.Generators) > 0 {
+			games = append(games, entries)
+		}
+	}
+	return games
+}
+
+// FormUrl is the url the front page form of the generator is posted to
+func FormUrl(generator BaseGenerator) string {
+	return "/create/" + generator.Name()
+}
+
+// BuildUrl creates the signature url from the submitted form values, returns the url and its path parameters
+func BuildUrl(generator BaseGenerator, form url.Values) (string, map[string]string, error) {
+	pathParams := make(map[string]string)
+	var query []string
+	params := generator.Metadata().Params
+
+	for _, param := range params {
+		value := strings.TrimSpace(form.Get(param.Name))
+		if param.Type == ParamUsername && form.Get("hide") == "on" {
+			value = util.HideUsername(value)
+		}
+
+		if strings.Contains(generator.Url(), ":"+param.Name) {
+			if value == "" {
+				return "", nil, errors.New(param.Label + " is required")
+			}
+			pathParams[param.Name] = value
+			continue
+		}
+
+		switch param.Type {
+		case ParamSkillGoals:
+			for _, row := range param.Rows() {
+				skill := form.Get(fmt.Sprintf("%s_skill_%d", param.Name, row))
+				goal := strings.TrimSpace(form.Get(fmt.Sprintf("%s_goal_%d", param.Name, row)))
+				if skill == "" || goal == "" {
+					continue
+				}
+				query = append(query, url.QueryEscape(strings.ToLower(skill))+"="+url.QueryEscape(goal))
+			}
+		case ParamBool:
+			if value == "on" || value == "true" {
+				query = append(query, url.QueryEscape(param.Name)+"=true")
+			}
+		default:
+			if value != "" && value != param.Default {
+				query = append(query, url.QueryEscape(param.Name)+"="+url.QueryEscape(value))
+			}
+		}
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(generator.Url(), "/") {
+		if strings.HasPrefix(segment, ":") {
+			segment = url.PathEscape(pathParams[segment[1:]])
+		}
+		segments = append(segments, segment)
+	}
+	signatureUrl := strings.Join(segments, "/")
+	if len(query) > 0 {
+		signatureUrl += "?" + strings.Join(query, "&")
+	}
+	return signatureUrl, pathParams, nil
+}
+
+// ValidateUrl parses the signature url with the generator to make sure it creates a valid signature
+func ValidateUrl(generator BaseGenerator, signatureUrl string, pathParams map[string]string) error {
+	request, err := http.NewRequest("GET", signatureUrl, nil)
+	if err != nil {
+		return err
+	}
+	_, err = generator.ParseSignatureRequest(web.C{URLParams: pathParams}, request)
+	return err
+}
+
+// Describe a hiscore table parameter, shared by the generators that read stats
+func HiscoreTableParam(game *util.Game) Param {
+	return Param{
+		Name:        util.HiscoreTableParam,
+		Type:        ParamSelect,
+		Label:       "Hiscores",
+		Description: "Hiscore table the stats are read from",
+		Default:     util.TableNormal.String(),
+		Options:     game.HiscoreTables(),
+	}
+}
+
+// Describe the flag that switches the levels of a signature to virtual levels
+func VirtualParam() Param {
+	return Param{
+		Name:        util.VirtualParam,
+		Type:        ParamBool,
+		Label:       "Show virtual levels",
+		Description: "Show levels above 99 and 120 based on xp",
+	}
+}
+
+// Describe the flag that adds the estimated time to goal to a signature
+func ETAParam() Param {
+	return Param{
+		Name:        util.ETAParam,
+		Type:        ParamBool,
+		Label:       "Show time to goal",
+		Description: "Estimate the time to goal from the recent xp rate",
+	}
+}
+
+// Describe the xp per hour rate the time to goal falls back to
+func RateParam() Param {
+	return Param{
+		Name:        util.RateParam,
+		Type:        ParamNumber,
+		Label:       "XP/hour",
+		Description: "XP per hour used for the time to goal when there's no recent xp history",
+		Min:         0,
+		Max:         util.XPMax,
+	}
+}
+
+// Describe the scale factor signatures can be rendered at for high-DPI screens
+func ScaleParam() Param {
+	var options []string
+	for scale := 1; scale <= util.MaxScale; scale++ {
+		options = append(options, strconv.Itoa(scale))
+	}
+	return Param{
+		Name:        util.ScaleParam,
+		Type:        ParamSelect,
+		Label:       "Scale",
+		Description: "Render at 2x or 3x the size for high-DPI screens, the url can also end in @2x",
+		Default:     "1",
+		Options:     options,
+	}
+}
+
+// Describe a username parameter, shared by all generators
+func UsernameParam() Param {
+	return Param{
+		Name:        "username",
+		Type:        ParamUsername,
+		Label:       "Username",
+		Description: "Display name of the player",
+		Min:         1,
+		Max:         12,
+	}
+}
+
+// Describe a level or xp goal parameter for skills of the game, the form suggests 99 in both games
+func GoalParam(name string, game *util.Game) Param {
+	return Param{
+		Name:        name,
+		Type:        ParamGoal,
+		Label:       "Goal",
+		Description: "Level or xp goal, xp goals can use 'k' or 'm' suffixes",
+		Min:         1,
+		Max:         game.XPMax,
+		Default:     "99",
+	}
+}
@@ -3,11 +3,7 @@ package rs3
 import (
 	"errors"
 	"fmt"
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
 	"github.com/zenazn/goji/web"
-	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
 	"image/draw"
@@ -15,257 +11,343 @@ import (
 	"net/http"
 	"os"
 	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/history"
 	"github.com/cubeee/go-sig/signature/util"
+	xdraw "golang.org/x/image/draw"
 	"strconv"
 )
 
 var (
-	baseWidth    = 161
-	baseHeight   = 80
-	baseImage    *image.RGBA
-	dpi          = 72.0
-	baseFont     = util.LoadFont("./resources/assets/fonts/MuseoSans_500.ttf")
-	fontColor    = image.NewUniform(color.RGBA{245, 178, 65, 255})
-	size         = 12.0
-	staticLabels = []StaticLabel{
-		{"Current XP:", 7, 15},
-		{"Target lvl:", 7, 30},
-		{"Remainder:", 7, 45},
-	}
+	baseWidth  = 161
+	baseHeight = 80
+	// Base images by scale factor
+	baseImages = make(map[int]*image.RGBA)
+	dpi        = 72.0
+	baseFont   = util.LoadFont("./resources/assets/fonts/MuseoSans_500.ttf")
+	fontColor  = image.NewUniform(color.RGBA{245, 178, 65, 255})
+	size       = 12.0
 )
 
 func init() {
-	baseImage = loadBaseImage()
-}
-
-type StaticLabel struct {
-	str string
-	x   int
-	y   int
+	// base.png is the normal size, larger scales are scaled up from it
+	baseImages[1] = loadBaseImage()
+	for scale := 2; scale <= util.MaxScale; scale++ {
+		baseImages[scale] = scaleBaseImage(baseImages[1], scale)
+	}
 }
 
 type BoxGoalGenerator struct {
-	generators.Generator
+	Game  *util.Game
+	Stats util.StatsProvider
+	// History is used to estimate the time to goal, it may be nil
+	History *history.Store
 }
 
-func (b BoxGoalGenerator) CreateSignature(req util.ParsedSignatureRequest) (util.Signature, error) {
-	username := req.GetProperty("username").(string)
-	skill := req.GetProperty("skill").(util.Skill)
-	goal := req.GetProperty("goal").(int)
-	goalType := req.GetProperty("goalType").(util.GoalType)
-
-	stats, err := util.GetStats(username)
-	if err != nil {
-		var s util.Signature
-		return s, errors.New(fmt.Sprintf("Failed to fetch stats for %s", username))
-	}
-	stat := util.GetStatBySkill(stats, skill)
+type BoxGoalRequest struct {
+	Username string
+	Skill    util.Skill
+	Goal     int
+	GoalType util.GoalType
+	Table    util.HiscoreTable
+	Virtual  bool
+	ETA      bool
+	// Rate is the xp per hour the time to goal falls back to
+	Rate  int
+	Scale int
+}
 
-	currentLevel := util.LevelFromXP(stat.Skill, stat.Xp)
-	currentXP := stat.Xp
-	var goalXP int
-	var remainder int
-	if goalType == util.GoalXP {
-		goalXP = goal
-		remainder = goalXP - currentXP
-	} else {
-		goalXP = util.XPForLevel(stat.Skill, goal)
-		remainder = util.XPToLevel(stat.Skill, currentXP, goal)
-	}
-	goalLevel := util.LevelFromXP(stat.Skill, goalXP)
-	if remainder < 0 {
-		remainder = 0
+func (r BoxGoalRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
 	}
-	percent := int(float64(currentXP) / float64(goalXP) * 100.0)
-	if percent > 100 {
-		percent = 100
+	if err := util.ValidateGoal(r.Skill, r.Goal, r.GoalType); err != nil {
+		return err
 	}
+	return util.ValidateRate(r.Rate)
+}
 
-	c := freetype.NewContext()
-
-	createDrawer := func(img draw.Image, color *image.Uniform, f *truetype.Font,
-		size float64, dpi float64, hinting font.Hinting) *font.Drawer {
-		return &font.Drawer{
-			Dst: img,
-			Src: color,
-			Face: truetype.NewFace(f, &truetype.Options{
-				Size:    size,
-				DPI:     dpi,
-				Hinting: hinting,
-			})}
+func (r BoxGoalRequest) Hash() string {
+	hash := fmt.Sprintf("%s-%d-%d-%s", r.Username, r.Skill.Id, r.Goal, r.Table)
+	if r.Virtual {
+		hash += "-virtual"
 	}
-
-	baseImage := cloneImage(baseImage)
-
-	drawer := createDrawer(baseImage, fontColor, baseFont, size, dpi,
-		font.HintingFull)
-
-	drawString := func(str string, x fixed.Int26_6, y int) {
-		drawer.Dot = fixed.Point26_6{
-			X: x,
-			//Y: fixed.I(y + int((c.PointToFixed(size) >> 6))),
-			Y: fixed.I(y + int(c.PointToFixed(size)>>6)),
-		}
-		drawer.DrawString(str)
+	if r.ETA {
+		hash += fmt.Sprintf("-eta-%d", r.Rate)
 	}
-
-	drawRightAlignedString := func(str string, x, y int) {
-		width := drawer.MeasureString(str)
-		drawString(str, fixed.I(x)-width, y)
+	if r.Scale > 1 {
+		hash += fmt.Sprintf("-%dx", r.Scale)
 	}
+	return hash
+}
 
-	// Skill name and current level
-	drawString(
-		fmt.Sprintf("%s: %d/%d", skill.Name, currentLevel, goalLevel),
-		fixed.I(7), 1)
+func NewBoxGoalGenerator(game *util.Game, stats util.StatsProvider, store *history.Store) *BoxGoalGenerator {
+	return &BoxGoalGenerator{Game: game, Stats: stats, History: store}
+}
 
-	for _, label := range staticLabels {
-		if label.str == "Target lvl:" && goalType == util.GoalXP {
-			label.str = "Target XP:"
-		}
+func (b BoxGoalGenerator) CreateSignature(req BoxGoalRequest) (util.Signature, error) {
+	username, skill, goal, goalType := req.Username, req.Skill, req.Goal, req.GoalType
 
-		drawString(label.str, fixed.I(label.x), label.y)
+	player := util.Player{Name: username, Game: b.Game.Mode, Table: req.Table}
+	stats, err := b.Stats.GetStats(player)
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", username, err)
 	}
+	stat := util.GetStatBySkill(stats, skill)
 
-	x, y := 150, 15
+	progress := util.Progress(stat, goal, goalType, req.Virtual)
 
-	// current xp
-	drawRightAlignedString(util.Format(currentXP), x, y)
-	y += 15
+	eta := ""
+	if req.ETA {
+		eta = GoalETA(b.History, player, skill, progress.Remainder, req.Rate)
+	}
+	sig := DrawGoal(skill, goal, goalType, progress, eta, req.Scale)
 
-	// goal
-	drawRightAlignedString(util.Format(goal), x, y)
-	y += 15
+	sig.Username = username
+	return sig, nil
+}
 
-	// remainder
-	drawRightAlignedString(util.Format(remainder), x, y)
-	y += 15
+// Create a signature showing the message instead of the goal
+func (b BoxGoalGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	baseImage := cloneImage(baseImages[1])
 
-	// bar
-	drawBar(baseImage, percent)
+	drawer := util.NewTextDrawer(baseImage, fontColor, baseFont, size, dpi)
+	drawer.DrawCentered(message, baseWidth/2, 22)
 
-	// bar percentage
-	x = 71
-	y = 61
-	textColor := image.White
-	if percent >= 50 {
-		textColor = image.Black
-	}
-
-	drawer = createDrawer(baseImage, textColor, baseFont, 11, dpi, font.HintingFull)
-	drawString(fmt.Sprintf("%d%%", percent), fixed.I(x), y)
-
-	return util.Signature{Username: username, Image: baseImage}, nil
+	return util.Signature{Image: baseImage}, nil
 }
 
 func (b BoxGoalGenerator) Name() string {
-	return "box"
+	return b.Game.GeneratorName("box")
 }
 
 func (b BoxGoalGenerator) Url() string {
-	return "/:username/:skill/:goal"
+	return b.Game.Route("/:username/:skill/:goal")
 }
 
-func (b BoxGoalGenerator) FormUrl() string {
-	return "/tooltip/create"
-}
-
-func (b BoxGoalGenerator) CreateHash(req util.ParsedSignatureRequest) string {
-	skill := req.GetProperty("skill").(util.Skill)
-	return fmt.Sprintf("%s-%d-%d", req.GetProperty("username"), skill.Id, req.GetProperty("goal"))
-}
-
-func (b BoxGoalGenerator) HandleForm(c web.C, writer http.ResponseWriter, request *http.Request) {
-	request.ParseForm()
-	form := request.Form
-	username := form.Get("username")
-	skill := form.Get("skill")
-	goal := form.Get("goal")
-
-	hideUsername := form.Get("hide")
-	if hideUsername == "on" && util.AesKey != nil {
-		name, err := util.Encrypt(username)
-		if err == nil {
-			name = "_" + name
-		}
-		username = name
+func (b BoxGoalGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Skill interface tooltip",
+		Description: "Progress towards a level or xp goal in a single skill",
+		Game:        b.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(b.Game),
+			{
+				Name:        "skill",
+				Type:        generators.ParamSkill,
+				Label:       "Skill",
+				Description: "Skill name or id",
+			},
+			generators.GoalParam("goal", b.Game),
+			generators.VirtualParam(),
+			generators.ETAParam(),
+			generators.RateParam(),
+			generators.ScaleParam(),
+		},
+		Example: "/assets/img/box_example.png",
 	}
-
-	// todo: validate input?
-
-	url := fmt.Sprintf("/%s/%s/%s", username, skill, goal)
-	util.ServeResultPage(writer, url)
 }
 
 // Parse the request into a signature request
-func (b BoxGoalGenerator) ParseSignatureRequest(c web.C, r *http.Request) (util.ParsedSignatureRequest, error) {
-	req := util.NewSignatureRequest()
+func (b BoxGoalGenerator) ParseRequest(c web.C, r *http.Request) (BoxGoalRequest, error) {
+	var req BoxGoalRequest
 
 	username := util.ParseUsername(c.URLParams["username"])
-	usernameLength := len(username)
-	if !util.UsernameRegex.MatchString(username) {
-		return req, errors.New("invalid username entered, allowed characters: alphabets, numbers, _ and +")
-	}
-	if usernameLength < 1 || usernameLength > 12 {
-		return req, errors.New("username has to be between 1 and 12 characters long")
-	}
 
 	// Read the skill id and make sure it is numeric
 	id, err := strconv.Atoi(c.URLParams["skill"])
 	var skill util.Skill
 	if err == nil {
 		// Get the skill by id
-		skill, err = util.GetSkillById(id)
+		skill, err = b.Game.GetSkillById(id)
 		if err != nil {
-			return req, errors.New(fmt.Sprintf("no skill found for the given id, make sure it is between 0 and %d", len(util.Skills)))
+			return req, errors.New(fmt.Sprintf("no skill found for the given id, make sure it is between 0 and %d", len(b.Game.Skills)))
 		}
 	} else {
 		// Get the skill by name
-		skill, err = util.GetSkillByName(c.URLParams["skill"])
+		skill, err = b.Game.GetSkillByName(c.URLParams["skill"])
 		if err != nil {
 			return req, errors.New("no skill found for the given skill name")
 		}
 	}
 
-	// Read the level and make sure it is numeric
-	goal, err := strconv.Atoi(c.URLParams["goal"])
+	// Read the goal, xp goals may have a 'k' or 'm' suffix
+	goal, err := util.FromSuffixed(c.URLParams["goal"])
+	if err != nil {
+		return req, errors.New("invalid goal entered, make sure it is numeric or has 'k'/'m' suffix")
+	}
+
+	// Switch the goal type if the goal exceeds the maximum skill level
+	goalType := util.GetGoalType(skill, goal)
+
+	query := r.URL.Query()
+	table, err := util.ParseHiscoreTable(b.Game, query.Get(util.HiscoreTableParam))
+	if err != nil {
+		return req, err
+	}
+	virtual, err := util.ParseVirtual(query.Get(util.VirtualParam))
+	if err != nil {
+		return req, err
+	}
+	eta, err := util.ParseFlag(util.ETAParam, query.Get(util.ETAParam))
+	if err != nil {
+		return req, err
+	}
+	rate, err := util.ParseRate(query.Get(util.RateParam))
+	if err != nil {
+		return req, err
+	}
+	scale, err := util.ParseScale(query.Get(util.ScaleParam))
 	if err != nil {
-		return req, errors.New("invalid goal entered, make sure it is numeric")
+		return req, err
 	}
 
-	// Make sure the level is within valid bounds
-	if goal < 0 || goal > 200000000 {
-		return req, errors.New("invalid level/xp goal entered, make sure it 0-200,000,000")
+	return BoxGoalRequest{
+		Username: username,
+		Skill:    skill,
+		Goal:     goal,
+		GoalType: goalType,
+		Table:    table,
+		Virtual:  virtual,
+		ETA:      eta,
+		Rate:     rate,
+		Scale:    scale,
+	}, nil
+}
+
+// DrawGoal draws the progress towards a goal in the box layout at the given scale. Unless eta is empty the
+// estimated time to goal is added as a fourth row and the rows are drawn smaller to fit.
+func DrawGoal(skill util.Skill, goal int, goalType util.GoalType, progress util.GoalProgress, eta string, scale int) util.Signature {
+	// Skill name and current level
+	title := fmt.Sprintf("%s: %d/%d", skill.Name, progress.CurrentLevel, progress.GoalLevel)
+
+	targetLabel := "Target lvl:"
+	if goalType == util.GoalXP {
+		targetLabel = "Target XP:"
+	}
+	rows := []boxRow{
+		{"Current XP:", util.Format(progress.CurrentXP)},
+		{targetLabel, util.Format(goal)},
+		{"Remainder:", util.Format(progress.Remainder)},
 	}
+	if eta != "" {
+		rows = append(rows, boxRow{"ETA:", eta})
+	}
+	return drawBox(title, rows, progress.Percent, scale)
+}
 
-	// Switch the goal type if the goal exceeds the maximum skill level
-	goalType := util.GoalLevel
-	if (skill.Id == util.InventionId && goal > util.InventionLevelMax) || (skill.Id != util.InventionId && goal > util.LevelMax) {
-		goalType = util.GoalXP
+// GoalETA returns the estimated time to gain the remaining xp as shown on the box, the store may be nil
+func GoalETA(store *history.Store, player util.Player, skill util.Skill, remainder, rate int) string {
+	if remainder == 0 {
+		return "Done"
+	}
+	if duration, ok := store.ETA(player, skill, remainder, rate); ok {
+		return util.FormatDuration(duration)
+	}
+	return "Unknown"
+}
+
+// boxRow is a label and a right aligned value drawn on one row of the box
+type boxRow struct {
+	label string
+	value string
+}
+
+// Returns the height and font size of the rows, a fourth row only fits above the bar with smaller text
+func rowLayout(rows []boxRow) (int, float64) {
+	if len(rows) > 3 {
+		return 11, 10
+	}
+	return 15, size
+}
+
+// Draw the title, up to four rows and a progress bar on a copy of the box base image, along with the same box
+// as a vector. The positions are the same at every scale.
+func drawBox(title string, rows []boxRow, percent, scale int) util.Signature {
+	baseImage := cloneImage(baseImages[scale])
+
+	drawer := util.NewScaledTextDrawer(baseImage, fontColor, baseFont, size, dpi, scale)
+	drawer.DrawString(title, 7, 1)
+
+	rowHeight, rowSize := rowLayout(rows)
+	drawer = util.NewScaledTextDrawer(baseImage, fontColor, baseFont, rowSize, dpi, scale)
+	x, y := 150, 15
+	for _, row := range rows {
+		drawer.DrawString(row.label, 7, y)
+		drawer.DrawRightAligned(row.value, x, y)
+		y += rowHeight
 	}
 
-	req.AddProperty("username", username)
-	req.AddProperty("id", id)
-	req.AddProperty("goal", goal)
-	req.AddProperty("skill", skill)
-	req.AddProperty("goalType", goalType)
-	return req, nil
+	drawBar(baseImage, percent, scale)
+
+	textColor := image.White
+	if percent >= 50 {
+		textColor = image.Black
+	}
+	drawer = util.NewScaledTextDrawer(baseImage, textColor, baseFont, 11, dpi, scale)
+	drawer.DrawString(fmt.Sprintf("%d%%", percent), 71, 62)
+
+	vector := func() *util.SVG {
+		svg := drawBoxVector(title, rows, percent)
+		svg.Scale = scale
+		return svg
+	}
+	return util.Signature{Image: baseImage, Vector: vector}
 }
 
-func drawBar(img draw.Image, percent int) {
+// Draw the box the same way as drawBox on top of base.png, only the text and the bar are vectors
+func drawBoxVector(title string, rows []boxRow, percent int) *util.SVG {
+	svg := util.NewSVG(baseWidth, baseHeight)
+	// Encoding an image in memory doesn't fail
+	svg.Image(baseImages[1], 0, 0)
+
+	drawer := util.NewSVGTextDrawer(svg, fontColor, baseFont, size, dpi)
+	drawer.DrawString(title, 7, 1)
+
+	rowHeight, rowSize := rowLayout(rows)
+	drawer = util.NewSVGTextDrawer(svg, fontColor, baseFont, rowSize, dpi)
+	x, y := 150, 15
+	for _, row := range rows {
+		drawer.DrawString(row.label, 7, y)
+		drawer.DrawRightAligned(row.value, x, y)
+		y += rowHeight
+	}
+
+	svg.Rect(15, 62, int(135.0*(float64(percent)/100.0)), 14, color.RGBA{G: 255, A: 255})
+
+	textColor := image.White
+	if percent >= 50 {
+		textColor = image.Black
+	}
+	drawer = util.NewSVGTextDrawer(svg, textColor, baseFont, 11, dpi)
+	drawer.DrawString(fmt.Sprintf("%d%%", percent), 71, 62)
+
+	return svg
+}
+
+func drawBar(img draw.Image, percent, scale int) {
 	x := 15
 	y := 62
 	width := int(135.0 * (float64(percent) / 100.0))
 	height := 14
 
 	green := color.RGBA{R: 0, G: 255, B: 0, A: 255}
-	bar := image.Rect(x, y, x+width, y+height)
+	bar := util.ScaleRect(image.Rect(x, y, x+width, y+height), scale)
 	draw.Draw(img, bar, &image.Uniform{green}, image.ZP, draw.Src)
 }
 
+// Scale the base image up by a whole factor. Nearest neighbour keeps every border pixel of base.png sharp and
+// in its own color.
+func scaleBaseImage(base *image.RGBA, scale int) *image.RGBA {
+	scaled := image.NewRGBA(util.ScaleRect(base.Bounds(), scale))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), base, base.Bounds(), xdraw.Src, nil)
+	return scaled
+}
+
 // Load base image to memory
 func loadBaseImage() *image.RGBA {
-	baseImageHandle, _ := os.Open("resources/assets/img/base.png")
+	baseImageHandle, _ := os.Open(util.ResourcePath("resources/assets/img/base.png"))
 	defer baseImageHandle.Close()
 	baseImage := image.NewRGBA(image.Rect(0, 0, baseWidth, baseHeight))
 	img, _ := png.Decode(baseImageHandle)
@@ -1,17 +1,61 @@
 package rs3
 
 import (
-	"errors"
+	"fmt"
 	"github.com/zenazn/goji/web"
 	"image"
 	"image/color"
 	"image/draw"
+	"net/http"
 	"github.com/cubeee/go-sig/signature/generators"
 	"github.com/cubeee/go-sig/signature/util"
 )
 
+var (
+	exampleFont     = util.LoadFont("./resources/assets/fonts/MuseoSans_500.ttf")
+	exampleFontSize = 12.0
+	exampleDPI      = 72.0
+)
+
+// Background colors the example signature can be drawn with, the first one is the default
+var (
+	exampleColorNames = []string{"blue", "green", "red"}
+	exampleColors     = map[string]color.RGBA{
+		"blue":  {R: 0, G: 0, B: 255, A: 255},
+		"green": {R: 0, G: 128, B: 0, A: 255},
+		"red":   {R: 160, G: 0, B: 0, A: 255},
+	}
+)
+
+// ExampleGenerator is a minimal generator meant as a starting point for new generators. It greets the player
+// on a colored background and doesn't fetch any stats.
 type ExampleGenerator struct {
-	generators.Generator
+}
+
+// Fail the build if the generator stops satisfying the generator interface
+var _ generators.Generator[ExampleRequest] = ExampleGenerator{}
+
+type ExampleRequest struct {
+	Username string
+	Color    string
+}
+
+func (r ExampleRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	if _, ok := exampleColors[r.Color]; !ok {
+		return fmt.Errorf("unknown color '%s'", r.Color)
+	}
+	return nil
+}
+
+func (r ExampleRequest) Hash() string {
+	return fmt.Sprintf("%s-%s", r.Username, r.Color)
+}
+
+func NewExampleGenerator() *ExampleGenerator {
+	return &ExampleGenerator{}
 }
 
 func (g ExampleGenerator) Name() string {
@@ -22,34 +66,59 @@ func (g ExampleGenerator) Url() string {
 	return "/hello/:username"
 }
 
-func (g ExampleGenerator) CreateSignature(req util.ParsedSignatureRequest) (util.Signature, error) {
-	username := req.GetProperty("username").(string)
+func (g ExampleGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Hello world",
+		Description: "Example generator greeting the player",
+		Game:        util.RS3Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			{
+				Name:        "color",
+				Type:        generators.ParamSelect,
+				Label:       "Color",
+				Description: "Background color",
+				Default:     exampleColorNames[0],
+				Options:     exampleColorNames,
+			},
+		},
+		Example: "/assets/img/hello_example.png",
+	}
+}
+
+// Parse the request into a signature request
+func (g ExampleGenerator) ParseRequest(c web.C, r *http.Request) (ExampleRequest, error) {
+	backgroundColor := r.URL.Query().Get("color")
+	if backgroundColor == "" {
+		backgroundColor = exampleColorNames[0]
+	}
+	return ExampleRequest{
+		Username: util.ParseUsername(c.URLParams["username"]),
+		Color:    backgroundColor,
+	}, nil
+}
 
-	baseImage := image.NewRGBA(image.Rect(0, 0, 500, 100))
-	blue := color.RGBA{R: 0, G: 0, B: 255, A: 255}
+func (g ExampleGenerator) CreateSignature(req ExampleRequest) (util.Signature, error) {
+	baseImage := createExampleImage(exampleColors[req.Color])
 
-	draw.Draw(baseImage, baseImage.Bounds(), &image.Uniform{blue}, image.ZP, draw.Src)
+	drawer := util.NewTextDrawer(baseImage, image.White, exampleFont, exampleFontSize, exampleDPI)
+	drawer.DrawString("Hello, "+req.Username+"!", 7, 7)
 
-	return util.Signature{Username: username, Image: baseImage}, nil
+	return util.Signature{Username: req.Username, Image: baseImage}, nil
 }
 
-func (g ExampleGenerator) CreateHash(req util.ParsedSignatureRequest) string {
-	username := req.GetProperty("username").(string)
-	return username
-}
+// Create a signature showing the message instead of the greeting
+func (g ExampleGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	baseImage := createExampleImage(exampleColors[exampleColorNames[0]])
 
-func (g ExampleGenerator) ParseSignatureRequest(c web.C) (util.ParsedSignatureRequest, error) {
-	req := util.NewSignatureRequest()
+	drawer := util.NewTextDrawer(baseImage, image.White, exampleFont, exampleFontSize, exampleDPI)
+	drawer.DrawString(message, 7, 7)
 
-	username := c.URLParams["username"]
-	usernameLength := len(username)
-	if !util.UsernameRegex.MatchString(username) {
-		return req, errors.New("invalid username entered, allowed characters: alphabets, numbers, _ and +")
-	}
-	if usernameLength < 1 || usernameLength > 12 {
-		return req, errors.New("username has to be between 1 and 12 characters long")
-	}
+	return util.Signature{Image: baseImage}, nil
+}
 
-	req.AddProperty("username", username)
-	return req, nil
+func createExampleImage(background color.RGBA) *image.RGBA {
+	baseImage := image.NewRGBA(image.Rect(0, 0, 300, 30))
+	draw.Draw(baseImage, baseImage.Bounds(), &image.Uniform{background}, image.ZP, draw.Src)
+	return baseImage
 }
@@ -0,0 +1,78 @@
+package rs3
+
+import (
+	"image"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zenazn/goji/web"
+)
+
+func TestExampleRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ExampleRequest
+		wantErr bool
+	}{
+		{"default color", ExampleRequest{Username: "Zezima", Color: "blue"}, false},
+		{"other color", ExampleRequest{Username: "Zezima", Color: "red"}, false},
+		{"unknown color", ExampleRequest{Username: "Zezima", Color: "pink"}, true},
+		{"empty color", ExampleRequest{Username: "Zezima", Color: ""}, true},
+		{"empty username", ExampleRequest{Username: "", Color: "blue"}, true},
+		{"long username", ExampleRequest{Username: "Zezima1234567", Color: "blue"}, true},
+		{"invalid characters", ExampleRequest{Username: "Zez ima", Color: "blue"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.req.Validate()
+			if (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExampleGeneratorParseRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		url      string
+		want     ExampleRequest
+		wantErr  bool
+	}{
+		{"defaults", "Zezima", "/hello/Zezima", ExampleRequest{Username: "Zezima", Color: "blue"}, false},
+		{"color", "Zezima", "/hello/Zezima?color=green", ExampleRequest{Username: "Zezima", Color: "green"}, false},
+		{"bad color", "Zezima", "/hello/Zezima?color=pink", ExampleRequest{Username: "Zezima", Color: "pink"}, true},
+		{"bad username", "Zez!ma", "/hello/Zez!ma", ExampleRequest{Username: "Zez!ma", Color: "blue"}, true},
+	}
+	generator := NewExampleGenerator()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := web.C{URLParams: map[string]string{"username": test.username}}
+			req, err := generator.ParseRequest(c, httptest.NewRequest("GET", test.url, nil))
+			if err != nil {
+				t.Fatalf("ParseRequest() error = %v", err)
+			}
+			if req != test.want {
+				t.Errorf("ParseRequest() = %+v, want %+v", req, test.want)
+			}
+			// Bad values are parsed as they are and rejected by the validation
+			if err := req.Validate(); (err != nil) != test.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExampleGeneratorCreateSignature(t *testing.T) {
+	sig, err := NewExampleGenerator().CreateSignature(ExampleRequest{Username: "Zezima", Color: "red"})
+	if err != nil {
+		t.Fatalf("CreateSignature() error = %v", err)
+	}
+	if sig.Username != "Zezima" {
+		t.Errorf("Username = %s, want Zezima", sig.Username)
+	}
+	if want := image.Rect(0, 0, 300, 30); sig.Image.Bounds() != want {
+		t.Errorf("Bounds() = %v, want %v", sig.Image.Bounds(), want)
+	}
+}
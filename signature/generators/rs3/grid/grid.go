@@ -0,0 +1,248 @@
+package grid
+
+import (
+	"fmt"
+	"github.com/zenazn/goji/web"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/util"
+	"github.com/cubeee/go-sig/signature"
+)
+
+var (
+	columns    = 3
+	dpi        = 72.0
+	baseFont   = util.LoadFont("./resources/assets/fonts/MuseoSans_500.ttf")
+	fontColor  = image.NewUniform(color.RGBA{245, 178, 65, 255})
+	background = color.RGBA{R: 0, G: 0, B: 0, A: 255}
+	barEmpty   = color.RGBA{R: 160, G: 0, B: 0, A: 255}
+	barFull    = color.RGBA{R: 0, G: 160, B: 0, A: 255}
+
+	// Skills in the order they appear in the in-game skills interface, row by row
+	layouts = map[util.GameMode][]string{
+		util.RS3: {
+			"Attack", "Constitution", "Mining",
+			"Strength", "Agility", "Smithing",
+			"Defence", "Herblore", "Fishing",
+			"Ranged", "Thieving", "Cooking",
+			"Prayer", "Crafting", "Firemaking",
+			"Magic", "Fletching", "Woodcutting",
+			"Runecrafting", "Slayer", "Farming",
+			"Construction", "Hunter", "Summoning",
+			"Dungeoneering", "Divination", "Invention",
+		},
+		util.OSRS: {
+			"Attack", "Hitpoints", "Mining",
+			"Strength", "Agility", "Smithing",
+			"Defence", "Herblore", "Fishing",
+			"Ranged", "Thieving", "Cooking",
+			"Prayer", "Crafting", "Firemaking",
+			"Magic", "Fletching", "Woodcutting",
+			"Runecraft", "Slayer", "Farming",
+			"Construction", "Hunter",
+		},
+	}
+)
+
+// Size decides the cell dimensions of the grid
+type Size string
+
+const (
+	SizeCompact Size = "compact"
+	SizeLarge   Size = "large"
+)
+
+var sizeNames = []string{string(SizeCompact), string(SizeLarge)}
+
+type cellStyle struct {
+	width, height int
+	fontSize      float64
+	barHeight     int
+	shortNames    bool
+}
+
+var cellStyles = map[Size]cellStyle{
+	SizeCompact: {width: 62, height: 17, fontSize: 11, barHeight: 2, shortNames: true},
+	SizeLarge:   {width: 136, height: 26, fontSize: 14, barHeight: 3},
+}
+
+const (
+	padding      = 5
+	headerHeight = 20
+)
+
+// GridGenerator draws every skill of the game in the layout of the in-game skills interface
+type GridGenerator struct {
+	Game  *util.Game
+	Stats util.StatsProvider
+}
+
+func NewGridGenerator(game *util.Game, stats util.StatsProvider) *GridGenerator {
+	return &GridGenerator{Game: game, Stats: stats}
+}
+
+type GridRequest struct {
+	Username string
+	Size     Size
+	Virtual  bool
+	Table    util.HiscoreTable
+}
+
+func (r GridRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	if _, ok := cellStyles[r.Size]; !ok {
+		return fmt.Errorf("unknown size '%s'", r.Size)
+	}
+	return nil
+}
+
+func (r GridRequest) Hash() string {
+	return fmt.Sprintf("%s-%s-%t-%s", r.Username, r.Size, r.Virtual, r.Table)
+}
+
+func (g GridGenerator) CreateSignature(req GridRequest) (util.Signature, error) {
+	stats, err := g.Stats.GetStats(util.Player{Name: req.Username, Game: g.Game.Mode, Table: req.Table})
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", req.Username, err)
+	}
+
+	layout := layouts[g.Game.Mode]
+	style := cellStyles[req.Size]
+	baseImage := loadBaseImage(style, len(layout))
+
+	overall := util.GetStatBySkill(stats, g.Game.Overall())
+	header := util.NewTextDrawer(baseImage, fontColor, baseFont, 12, dpi)
+	header.DrawString(req.Username, padding, padding)
+	header.DrawRightAligned(fmt.Sprintf("Total: %d", overall.Level), baseImage.Bounds().Dx()-padding, padding)
+
+	drawer := util.NewTextDrawer(baseImage, fontColor, baseFont, style.fontSize, dpi)
+	for i, name := range layout {
+		skill, err := g.Game.GetSkillByName(name)
+		if err != nil {
+			continue
+		}
+		stat := util.GetStatBySkill(stats, skill)
+		x := padding + (i%columns)*style.width
+		y := padding + headerHeight + (i/columns)*style.height
+		drawCell(baseImage, drawer, style, x, y, stat, req.Virtual)
+	}
+
+	return util.Signature{Username: req.Username, Image: baseImage}, nil
+}
+
+// Draw the name, level and the progress to the next level of a single skill
+func drawCell(img draw.Image, drawer *util.TextDrawer, style cellStyle, x, y int, stat util.Stat, virtual bool) {
+	level, maxLevel := util.LevelFromXP(stat.Skill, stat.Xp, virtual), util.RealLevelMax(stat.Skill)
+	if virtual {
+		maxLevel = util.MaxLevel(stat.Skill)
+	}
+
+	name := stat.Skill.Name
+	if style.shortNames {
+		name = stat.Skill.ShortName()
+	}
+	drawer.DrawString(name, x+2, y)
+	drawer.DrawRightAligned(fmt.Sprintf("%d", level), x+style.width-6, y)
+
+	// Skills at the highest shown level have a full bar
+	percent := 100
+	if next := level + 1; next <= maxLevel {
+		current, needed := util.XPForLevel(stat.Skill, level), util.XPForLevel(stat.Skill, next)
+		if stat.Xp < needed {
+			percent = int(float64(stat.Xp-current) / float64(needed-current) * 100.0)
+		}
+	}
+	barY := y + style.height - style.barHeight - 2
+	width := style.width - 8
+	draw.Draw(img, image.Rect(x+2, barY, x+2+width, barY+style.barHeight), &image.Uniform{barEmpty}, image.ZP, draw.Src)
+	draw.Draw(img, image.Rect(x+2, barY, x+2+width*percent/100, barY+style.barHeight), &image.Uniform{barFull}, image.ZP, draw.Src)
+}
+
+// Create a signature showing the message instead of the skills
+func (g GridGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	style := cellStyles[SizeCompact]
+	baseImage := loadBaseImage(style, columns)
+
+	drawer := util.NewTextDrawer(baseImage, fontColor, baseFont, 12, dpi)
+	drawer.DrawString(message, padding, padding)
+
+	// Watermark
+	drawer = util.NewTextDrawer(baseImage, fontColor, baseFont, 11, dpi)
+	drawer.DrawRightAligned(vars.VirtualHost, baseImage.Bounds().Dx()-padding, padding+headerHeight)
+
+	return util.Signature{Image: baseImage}, nil
+}
+
+func (g GridGenerator) Name() string {
+	return g.Game.GeneratorName("grid")
+}
+
+func (g GridGenerator) Url() string {
+	return g.Game.Route("/grid/:username")
+}
+
+func (g GridGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Skills interface",
+		Description: "Every skill with its level and progress to the next level",
+		Game:        g.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(g.Game),
+			{
+				Name:        "size",
+				Type:        generators.ParamSelect,
+				Label:       "Size",
+				Description: "Compact cells with short names or large cells with full names",
+				Default:     string(SizeCompact),
+				Options:     sizeNames,
+			},
+			generators.VirtualParam(),
+		},
+		Example: "/assets/img/grid_example.png",
+	}
+}
+
+// Parse the request into a signature request
+func (g GridGenerator) ParseRequest(c web.C, r *http.Request) (GridRequest, error) {
+	var req GridRequest
+
+	query := r.URL.Query()
+	table, err := util.ParseHiscoreTable(g.Game, query.Get(util.HiscoreTableParam))
+	if err != nil {
+		return req, err
+	}
+
+	size := Size(query.Get("size"))
+	if size == "" {
+		size = SizeCompact
+	}
+
+	virtual, err := util.ParseVirtual(query.Get(util.VirtualParam))
+	if err != nil {
+		return req, err
+	}
+
+	return GridRequest{
+		Username: util.ParseUsername(c.URLParams["username"]),
+		Size:     size,
+		Virtual:  virtual,
+		Table:    table,
+	}, nil
+}
+
+// Create a black image fitting the header and the given number of cells
+func loadBaseImage(style cellStyle, cells int) *image.RGBA {
+	rows := (cells + columns - 1) / columns
+	width := columns*style.width + padding*2
+	height := rows*style.height + headerHeight + padding*2
+	baseImage := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(baseImage, baseImage.Bounds(), &image.Uniform{background}, image.ZP, draw.Src)
+	return baseImage
+}
@@ -0,0 +1,191 @@
+package rs3
+
+import (
+	"fmt"
+	"github.com/zenazn/goji/web"
+	"net/http"
+	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/util"
+)
+
+// MaxTarget is the per skill target of the max cape generator
+type MaxTarget string
+
+const (
+	Target99   MaxTarget = "99"
+	Target120  MaxTarget = "120"
+	Target200m MaxTarget = "200m"
+)
+
+var maxTargetTitles = map[MaxTarget]string{
+	Target99:   "Max cape",
+	Target120:  "Level 120s",
+	Target200m: "200m all",
+}
+
+// XP returns the xp the skill needs to reach the target. Invention is an elite skill with its own xp curve,
+// the max cape asks for level 120 in it and the 120s target for its level 150 cap.
+func (t MaxTarget) XP(skill util.Skill) int {
+	elite := skill.Game == util.RS3 && skill.Id == util.InventionId
+	switch {
+	case t == Target99 && elite:
+		return util.XPForLevel(skill, 120)
+	case t == Target99:
+		return util.XPForLevel(skill, 99)
+	case t == Target120 && elite:
+		return util.XPForLevel(skill, util.InventionLevelMax)
+	case t == Target120:
+		return util.XPForLevel(skill, 120)
+	}
+	return util.XPMax
+}
+
+// MaxCapeGenerator shows the progress towards having every skill at 99, 120 or 200m xp
+type MaxCapeGenerator struct {
+	Game  *util.Game
+	Stats util.StatsProvider
+}
+
+type MaxCapeRequest struct {
+	Username string
+	Target   MaxTarget
+	Table    util.HiscoreTable
+	Scale    int
+}
+
+func (r MaxCapeRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	if _, ok := maxTargetTitles[r.Target]; !ok {
+		return fmt.Errorf("unknown target '%s'", r.Target)
+	}
+	return nil
+}
+
+func (r MaxCapeRequest) Hash() string {
+	hash := fmt.Sprintf("%s-%s-%s", r.Username, r.Target, r.Table)
+	if r.Scale > 1 {
+		hash += fmt.Sprintf("-%dx", r.Scale)
+	}
+	return hash
+}
+
+func NewMaxCapeGenerator(game *util.Game, stats util.StatsProvider) *MaxCapeGenerator {
+	return &MaxCapeGenerator{Game: game, Stats: stats}
+}
+
+func (m MaxCapeGenerator) CreateSignature(req MaxCapeRequest) (util.Signature, error) {
+	stats, err := m.Stats.GetStats(util.Player{Name: req.Username, Game: m.Game.Mode, Table: req.Table})
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", req.Username, err)
+	}
+
+	// Xp above the target doesn't count towards the progress of other skills
+	var gained, needed, skillsLeft int
+	for id := 0; id < len(m.Game.Skills); id++ {
+		skill := m.Game.Skills[id]
+		stat := util.GetStatBySkill(stats, skill)
+		target := req.Target.XP(skill)
+
+		needed += target
+		if stat.Xp >= target {
+			gained += target
+		} else {
+			gained += stat.Xp
+			skillsLeft++
+		}
+	}
+	percent := int(float64(gained) / float64(needed) * 100.0)
+	if percent == 100 && gained < needed {
+		percent = 99
+	}
+
+	sig := drawBox(maxTargetTitles[req.Target], []boxRow{
+		{"Skills done:", fmt.Sprintf("%d/%d", len(m.Game.Skills)-skillsLeft, len(m.Game.Skills))},
+		{"Skills left:", fmt.Sprintf("%d", skillsLeft)},
+		{"XP left:", util.Format(needed - gained)},
+	}, percent, req.Scale)
+
+	sig.Username = req.Username
+	return sig, nil
+}
+
+// Create a signature showing the message instead of the progress
+func (m MaxCapeGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	return BoxGoalGenerator{}.CreateErrorSignature(message)
+}
+
+func (m MaxCapeGenerator) Name() string {
+	return m.Game.GeneratorName("max")
+}
+
+func (m MaxCapeGenerator) Url() string {
+	return m.Game.Route("/max/:username")
+}
+
+// Targets returns the targets available in the game, OSRS has no levels above 99
+func (m MaxCapeGenerator) Targets() []string {
+	if m.Game.Mode == util.OSRS {
+		return []string{string(Target99), string(Target200m)}
+	}
+	return []string{string(Target99), string(Target120), string(Target200m)}
+}
+
+func (m MaxCapeGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Max cape",
+		Description: "Progress towards 99, 120 or 200m xp in every skill",
+		Game:        m.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(m.Game),
+			{
+				Name:        "target",
+				Type:        generators.ParamSelect,
+				Label:       "Target",
+				Description: "Level or xp every skill has to reach",
+				Default:     string(Target99),
+				Options:     m.Targets(),
+			},
+			generators.ScaleParam(),
+		},
+		Example: "/assets/img/max_example.png",
+	}
+}
+
+// Parse the request into a signature request
+func (m MaxCapeGenerator) ParseRequest(c web.C, r *http.Request) (MaxCapeRequest, error) {
+	var req MaxCapeRequest
+
+	query := r.URL.Query()
+	table, err := util.ParseHiscoreTable(m.Game, query.Get(util.HiscoreTableParam))
+	if err != nil {
+		return req, err
+	}
+
+	scale, err := util.ParseScale(query.Get(util.ScaleParam))
+	if err != nil {
+		return req, err
+	}
+
+	target := MaxTarget(query.Get("target"))
+	if target == "" {
+		target = Target99
+	}
+	valid := false
+	for _, option := range m.Targets() {
+		valid = valid || option == string(target)
+	}
+	if !valid {
+		return req, fmt.Errorf("unknown target '%s' for %s", target, m.Game.Title)
+	}
+
+	return MaxCapeRequest{
+		Username: util.ParseUsername(c.URLParams["username"]),
+		Target:   target,
+		Table:    table,
+		Scale:    scale,
+	}, nil
+}
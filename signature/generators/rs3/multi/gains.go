@@ -0,0 +1,280 @@
+package multi
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zenazn/goji/web"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/history"
+	"github.com/cubeee/go-sig/signature/util"
+	"sort"
+	"strconv"
+	"time"
+	"github.com/cubeee/go-sig/signature"
+)
+
+var (
+	periods = map[string]time.Duration{
+		"day":   24 * time.Hour,
+		"week":  7 * 24 * time.Hour,
+		"month": 30 * 24 * time.Hour,
+	}
+	periodNames    = []string{"day", "week", "month"}
+	sparklineColor = color.RGBA{R: 0, G: 160, B: 0, A: 255}
+)
+
+const (
+	defaultTop    = 5
+	maxTop        = 10
+	sparkWidth    = 120
+	sparkHeight   = 12
+	defaultPeriod = "week"
+	periodParam   = "period"
+	topParam      = "top"
+)
+
+// GainsGenerator ranks the skills of a player by the xp gained over a period, read from the recorded history
+type GainsGenerator struct {
+	Game    *util.Game
+	Stats   util.StatsProvider
+	History *history.Store
+}
+
+func NewGainsGenerator(game *util.Game, stats util.StatsProvider, store *history.Store) *GainsGenerator {
+	return &GainsGenerator{Game: game, Stats: stats, History: store}
+}
+
+type GainsRequest struct {
+	Username string
+	Period   string
+	Top      int
+	Table    util.HiscoreTable
+}
+
+func (r GainsRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	if _, ok := periods[r.Period]; !ok {
+		return fmt.Errorf("unknown period '%s'", r.Period)
+	}
+	if r.Top < 1 || r.Top > maxTop {
+		return fmt.Errorf("the number of skills has to be between 1 and %d", maxTop)
+	}
+	return nil
+}
+
+func (r GainsRequest) Hash() string {
+	return fmt.Sprintf("%s-%s-%d-%s", r.Username, r.Period, r.Top, r.Table)
+}
+
+type skillGain struct {
+	skill util.Skill
+	xp    int
+}
+
+func (g GainsGenerator) CreateSignature(req GainsRequest) (util.Signature, error) {
+	player := util.Player{Name: req.Username, Game: g.Game.Mode, Table: req.Table}
+	stats, err := g.Stats.GetStats(player)
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", req.Username, err)
+	}
+	now := time.Now()
+	snapshots, err := g.History.Snapshots(player, now.Add(-periods[req.Period]), now)
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to read the history of %s: %w", req.Username, err)
+	}
+
+	// The gains are counted from the oldest snapshot of the period to the current stats
+	var gains []skillGain
+	totalGain := 0
+	if len(snapshots) > 0 {
+		first := snapshots[0]
+		for id := 0; id < len(g.Game.Skills); id++ {
+			skill := g.Game.Skills[id]
+			if xp, ok := first.Xp[id]; ok {
+				if gain := util.GetStatBySkill(stats, skill).Xp - xp; gain > 0 {
+					gains = append(gains, skillGain{skill, gain})
+				}
+			}
+		}
+		if xp, ok := first.Xp[util.OverallId]; ok {
+			totalGain = util.GetStatBySkill(stats, g.Game.Overall()).Xp - xp
+		}
+	}
+	sort.SliceStable(gains, func(i, j int) bool {
+		return gains[i].xp > gains[j].xp
+	})
+	if len(gains) > req.Top {
+		gains = gains[:req.Top]
+	}
+
+	rows := len(gains)
+	if rows == 0 {
+		rows = 1
+	}
+	// One more row for the header
+	baseImage := loadBaseImage(rows+1, 1)
+	drawer := util.NewTextDrawer(baseImage, fontColor, baseFont, size, dpi)
+
+	nameX, gainX := paddingSides, baseWidth-paddingSides
+	y := paddingSides
+
+	drawer.DrawString(fmt.Sprintf("%s: xp this %s", req.Username, req.Period), nameX, y)
+	drawer.DrawRightAligned("+"+util.Format(totalGain), gainX, y)
+	y += baseHeight
+
+	if len(gains) == 0 {
+		drawer.DrawString("No xp gained this "+req.Period, nameX, y)
+		y += baseHeight
+	}
+	for _, gain := range gains {
+		drawer.DrawString(gain.skill.Name, nameX, y)
+		drawer.DrawRightAligned("+"+util.Format(gain.xp), gainX, y)
+
+		// Bars are relative to the skill with the most xp gained
+		percent := int(float64(gain.xp) / float64(gains[0].xp) * 100.0)
+		drawBar(baseImage, percent, nameX, y+20, baseWidth-5-paddingSides, 1, 1)
+
+		y += baseHeight
+	}
+
+	// Overall xp over the period, ending with the current stats
+	var points []int
+	for _, snapshot := range snapshots {
+		points = append(points, snapshot.Xp[util.OverallId])
+	}
+	points = append(points, util.GetStatBySkill(stats, g.Game.Overall()).Xp)
+	drawSparkline(baseImage, points, nameX, y+2, sparkWidth, sparkHeight)
+
+	// Watermark
+	y -= 1
+	drawer = util.NewTextDrawer(baseImage, fontColor, baseFont, 11, dpi)
+	drawer.DrawRightAligned(vars.VirtualHost, gainX, y)
+
+	return util.Signature{Username: req.Username, Image: baseImage}, nil
+}
+
+// Draw the values as a line scaled to fit the given rectangle
+func drawSparkline(img draw.Image, points []int, x, y, width, height int) {
+	if len(points) < 2 {
+		return
+	}
+	min, max := points[0], points[0]
+	for _, point := range points {
+		if point < min {
+			min = point
+		}
+		if point > max {
+			max = point
+		}
+	}
+	scale := 0.0
+	if max > min {
+		scale = float64(height-1) / float64(max-min)
+	}
+
+	// Interpolate the height of every column between the two points around it
+	columns := make([]int, width)
+	for px := range columns {
+		position := float64(px) / float64(width-1) * float64(len(points)-1)
+		i := int(position)
+		if i >= len(points)-1 {
+			i = len(points) - 2
+		}
+		value := float64(points[i]) + float64(points[i+1]-points[i])*(position-float64(i))
+		columns[px] = y + height - 1 - int((value-float64(min))*scale)
+	}
+
+	for px, top := range columns {
+		// Fill the gap to the previous column so steep changes stay connected
+		bottom := top
+		if px > 0 {
+			if columns[px-1] < top {
+				top = columns[px-1]
+			} else if columns[px-1] > bottom {
+				bottom = columns[px-1]
+			}
+		}
+		draw.Draw(img, image.Rect(x+px, top, x+px+1, bottom+1), &image.Uniform{sparklineColor}, image.ZP, draw.Src)
+	}
+}
+
+// Create a single row signature showing the message instead of the gains
+func (g GainsGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	return MultiGoalGenerator{}.CreateErrorSignature(message)
+}
+
+func (g GainsGenerator) Name() string {
+	return g.Game.GeneratorName("gains")
+}
+
+func (g GainsGenerator) Url() string {
+	return g.Game.Route("/gains/:username")
+}
+
+func (g GainsGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "XP gains",
+		Description: "Skills with the most xp gained over a day, week or month",
+		Game:        g.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(g.Game),
+			{
+				Name:        periodParam,
+				Type:        generators.ParamSelect,
+				Label:       "Period",
+				Description: "Period the gains are counted over",
+				Default:     defaultPeriod,
+				Options:     periodNames,
+			},
+			{
+				Name:        topParam,
+				Type:        generators.ParamNumber,
+				Label:       "Skills",
+				Description: "Number of skills shown",
+				Min:         1,
+				Max:         maxTop,
+				Default:     strconv.Itoa(defaultTop),
+			},
+		},
+		Example: "/assets/img/gains_example.png",
+	}
+}
+
+// Parse the request into a signature request
+func (g GainsGenerator) ParseRequest(c web.C, r *http.Request) (GainsRequest, error) {
+	var req GainsRequest
+
+	query := r.URL.Query()
+	table, err := util.ParseHiscoreTable(g.Game, query.Get(util.HiscoreTableParam))
+	if err != nil {
+		return req, err
+	}
+
+	period := query.Get(periodParam)
+	if period == "" {
+		period = defaultPeriod
+	}
+
+	top := defaultTop
+	if value := query.Get(topParam); value != "" {
+		if top, err = strconv.Atoi(value); err != nil {
+			return req, errors.New("invalid number of skills entered, make sure it is numeric")
+		}
+	}
+
+	return GainsRequest{
+		Username: util.ParseUsername(c.URLParams["username"]),
+		Period:   period,
+		Top:      top,
+		Table:    table,
+	}, nil
+}
@@ -1,22 +1,19 @@
 package multi
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"github.com/golang/freetype"
-	"github.com/golang/freetype/truetype"
 	"github.com/zenazn/goji/web"
-	"golang.org/x/image/font"
-	"golang.org/x/image/math/fixed"
 	"image"
 	"image/color"
 	"image/draw"
 	"net/http"
-	"net/url"
 	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/history"
 	"github.com/cubeee/go-sig/signature/util"
 	"strconv"
+	"time"
+	"github.com/cubeee/go-sig/signature/generators/rs3"
 	"github.com/cubeee/go-sig/signature"
 )
 
@@ -31,82 +28,128 @@ var (
 	size          = 15.0
 )
 
+const (
+	animateParam = "animate"
+	delayParam   = "delay"
+	loopParam    = "loop"
+	defaultDelay = 2000
+	minDelay     = 100
+	maxDelay     = 10000
+	maxLoops     = 100
+	maxGoals     = 5
+)
+
 type MultiGoalGenerator struct {
-	generators.Generator
+	Game  *util.Game
+	Stats util.StatsProvider
+	// History is used to estimate the time to goal, it may be nil
+	History *history.Store
+}
+
+func NewMultiGoalGenerator(game *util.Game, stats util.StatsProvider, store *history.Store) *MultiGoalGenerator {
+	return &MultiGoalGenerator{Game: game, Stats: stats, History: store}
 }
 
 type MultiGoal struct {
-	skill    util.Skill
-	goal     int
-	goalType util.GoalType
+	Skill    util.Skill
+	Goal     int
+	GoalType util.GoalType
 }
 
-func (m MultiGoalGenerator) CreateSignature(req util.ParsedSignatureRequest) (util.Signature, error) {
-	username := req.GetProperty("username").(string)
-	goals := req.GetProperty("goals").([]MultiGoal)
+type MultiGoalRequest struct {
+	Username string
+	Goals    []MultiGoal
+	Table    util.HiscoreTable
+	Virtual  bool
+	ETA      bool
+	// Rate is the xp per hour the time to goal falls back to
+	Rate int
+	// Animate shows one goal per frame of a GIF in the box layout instead of stacking the goals
+	Animate bool
+	// Delay is the time in milliseconds every frame is shown
+	Delay int
+	// Loops is the number of times the animation is played, 0 plays it forever
+	Loops int
+	Scale int
+}
 
-	stats, err := util.GetStats(username)
-	if err != nil {
-		var s util.Signature
-		return s, errors.New(fmt.Sprintf("Failed to fetch stats for %s", username))
+func (r MultiGoalRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
 	}
-
-	c := freetype.NewContext()
-
-	createDrawer := func(img draw.Image, color *image.Uniform, f *truetype.Font,
-		size float64, dpi float64, hinting font.Hinting) *font.Drawer {
-		return &font.Drawer{
-			Dst: img,
-			Src: color,
-			Face: truetype.NewFace(f, &truetype.Options{
-				Size:    size,
-				DPI:     dpi,
-				Hinting: hinting,
-			})}
+	if len(r.Goals) == 0 {
+		return errors.New("no goals entered, add at least one skill goal")
 	}
+	if len(r.Goals) > maxGoals {
+		return fmt.Errorf("too many goals entered, the maximum is %d", maxGoals)
+	}
+	for _, goal := range r.Goals {
+		if err := util.ValidateGoal(goal.Skill, goal.Goal, goal.GoalType); err != nil {
+			return err
+		}
+	}
+	if r.Animate {
+		if r.Delay < minDelay || r.Delay > maxDelay {
+			return fmt.Errorf("the frame delay has to be between %d and %d milliseconds", minDelay, maxDelay)
+		}
+		if r.Loops < 0 || r.Loops > maxLoops {
+			return fmt.Errorf("the number of loops has to be between 0 and %d", maxLoops)
+		}
+	}
+	return util.ValidateRate(r.Rate)
+}
 
-	baseImage := loadBaseImage(len(goals))
-
-	drawer := createDrawer(baseImage, fontColor, baseFont, size, dpi, font.HintingFull)
+// Animated signatures are always GIFs whatever format is asked for
+func (r MultiGoalRequest) Animated() bool {
+	return r.Animate
+}
 
-	drawString := func(str string, x fixed.Int26_6, y int) {
-		drawer.Dot = fixed.Point26_6{
-			X: x,
-			Y: fixed.I(y + int(c.PointToFixed(size)>>6)),
-		}
-		drawer.DrawString(str)
+func (r MultiGoalRequest) Hash() string {
+	goalStr := fmt.Sprintf("%s-%s", r.Username, r.Table)
+	for _, goal := range r.Goals {
+		goalStr = fmt.Sprintf("%s-%v-%v", goalStr, goal.Skill.Id, goal.Goal)
+	}
+	if r.Virtual {
+		goalStr += "-virtual"
 	}
+	if r.ETA {
+		goalStr = fmt.Sprintf("%s-eta-%d", goalStr, r.Rate)
+	}
+	if r.Animate {
+		goalStr = fmt.Sprintf("%s-animated-%d-%d", goalStr, r.Delay, r.Loops)
+	}
+	if r.Scale > 1 {
+		goalStr = fmt.Sprintf("%s-%dx", goalStr, r.Scale)
+	}
+	return util.GetMD5(goalStr)
+}
 
-	drawRightAlignedString := func(str string, x, y int) {
-		width := drawer.MeasureString(str)
-		drawString(str, fixed.I(x)-width, y)
+func (m MultiGoalGenerator) CreateSignature(req MultiGoalRequest) (util.Signature, error) {
+	username, goals := req.Username, req.Goals
+
+	player := util.Player{Name: username, Game: m.Game.Mode, Table: req.Table}
+	stats, err := m.Stats.GetStats(player)
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", username, err)
+	}
+	if req.Animate {
+		return m.createAnimation(req, player, stats), nil
 	}
 
+	baseImage := loadBaseImage(len(goals), req.Scale)
+
+	drawer := util.NewScaledTextDrawer(baseImage, fontColor, baseFont, size, dpi, req.Scale)
+
 	nameX, goalX := paddingSides, baseWidth-paddingSides
 	y := paddingSides
 
 	for _, goal := range goals {
-		stat := util.GetStatBySkill(stats, goal.skill)
-
-		currentLevel := util.LevelFromXP(stat.Skill, stat.Xp)
-		currentXP := stat.Xp
-		var goalXP int
-		var remainder int
-		if goal.goalType == util.GoalXP {
-			goalXP = goal.goal
-			remainder = goalXP - currentXP
-		} else {
-			goalXP = util.XPForLevel(stat.Skill, goal.goal)
-			remainder = util.XPToLevel(stat.Skill, currentXP, goal.goal)
-		}
-		goalLevel := util.LevelFromXP(stat.Skill, goalXP)
-		if remainder < 0 {
-			remainder = 0
-		}
-		percent := int(float64(currentXP) / float64(goalXP) * 100.0)
-		if percent > 100 {
-			percent = 100
-		}
+		stat := util.GetStatBySkill(stats, goal.Skill)
+
+		progress := util.Progress(stat, goal.Goal, goal.GoalType, req.Virtual)
+		currentLevel, currentXP := progress.CurrentLevel, progress.CurrentXP
+		goalLevel, goalXP := progress.GoalLevel, progress.GoalXP
 
 		if currentLevel > goalLevel {
 			currentLevel = goalLevel
@@ -116,155 +159,219 @@ func (m MultiGoalGenerator) CreateSignature(req util.ParsedSignatureRequest) (ut
 		}
 
 		// Skill name and current level
-		drawString(
-			fmt.Sprintf("%s: %d/%d", goal.skill.Name, currentLevel, goalLevel),
-			fixed.I(nameX), y)
+		name := fmt.Sprintf("%s: %d/%d", goal.Skill.Name, currentLevel, goalLevel)
+		if req.ETA && progress.Remainder > 0 {
+			if duration, ok := m.History.ETA(player, goal.Skill, progress.Remainder, req.Rate); ok {
+				name += " (" + util.FormatDuration(duration) + ")"
+			}
+		}
+		drawer.DrawString(name, nameX, y)
 
 		// Current and goal xp
-		drawRightAlignedString(util.Format(currentXP)+"/"+util.Format(goalXP), goalX, y)
+		drawer.DrawRightAligned(util.Format(currentXP)+"/"+util.Format(goalXP), goalX, y)
 
 		// Bar
-		drawBar(baseImage, percent, nameX, y+20, baseWidth-5-paddingSides, 1)
+		drawBar(baseImage, progress.Percent, nameX, y+20, baseWidth-5-paddingSides, 1, req.Scale)
 
 		y += baseHeight
 	}
 
 	// Watermark
-	y -= 5
-	drawer = createDrawer(baseImage, fontColor, baseFont, 11, dpi, font.HintingFull)
-	drawRightAlignedString(vars.VirtualHost, goalX, y)
+	y -= 1
+	drawer = util.NewScaledTextDrawer(baseImage, fontColor, baseFont, 11, dpi, req.Scale)
+	drawer.DrawRightAligned(vars.VirtualHost, goalX, y)
 
 	return util.Signature{Username: username, Image: baseImage}, nil
 }
 
-func (m MultiGoalGenerator) Name() string {
-	return "multi"
-}
+// Draw every goal in the box layout on a frame of its own
+func (m MultiGoalGenerator) createAnimation(req MultiGoalRequest, player util.Player, stats map[int]util.Stat) util.Signature {
+	var frames []image.Image
+	for _, goal := range req.Goals {
+		progress := util.Progress(util.GetStatBySkill(stats, goal.Skill), goal.Goal, goal.GoalType, req.Virtual)
 
-func (m MultiGoalGenerator) Url() string {
-	return "/multi/:username"
-}
+		eta := ""
+		if req.ETA {
+			eta = rs3.GoalETA(m.History, player, goal.Skill, progress.Remainder, req.Rate)
+		}
+		frames = append(frames, rs3.DrawGoal(goal.Skill, goal.Goal, goal.GoalType, progress, eta, req.Scale).Image)
+	}
 
-func (m MultiGoalGenerator) FormUrl() string {
-	return "/multi/create"
+	animation := util.NewAnimation(frames, time.Duration(req.Delay)*time.Millisecond, req.Loops)
+	return util.Signature{Username: req.Username, Image: frames[0], Animation: animation}
 }
 
-func (m MultiGoalGenerator) CreateHash(req util.ParsedSignatureRequest) string {
-	username := req.GetProperty("username").(string)
-	goals := req.GetProperty("goals").([]MultiGoal)
-	goalStr := username
-	for _, goal := range goals {
-		goalStr = fmt.Sprintf("%s-%v-%v", goalStr, goal.skill.Id, goal.goal)
-	}
-	return util.GetMD5(goalStr)
-}
+// Create a single row signature showing the message instead of the goals
+func (m MultiGoalGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	baseImage := loadBaseImage(1, 1)
 
-func (m MultiGoalGenerator) HandleForm(c web.C, writer http.ResponseWriter, request *http.Request) {
-	request.ParseForm()
-	form := request.Form
-	username := form.Get("username")
+	drawer := util.NewTextDrawer(baseImage, fontColor, baseFont, size, dpi)
+	drawer.DrawString(message, paddingSides, paddingSides)
 
-	// Workaround to preserve order
-	var buf bytes.Buffer
-	for id := 0; id < len(util.Skills); id++ {
-		skill := form.Get("skill_" + strconv.Itoa(id))
-		goal := form.Get("goal_" + strconv.Itoa(id))
+	// Watermark
+	drawer = util.NewTextDrawer(baseImage, fontColor, baseFont, 11, dpi)
+	drawer.DrawRightAligned(vars.VirtualHost, baseWidth-paddingSides, paddingSides+baseHeight-1)
 
-		if skill == "" || goal == "" {
-			continue
-		}
+	return util.Signature{Image: baseImage}, nil
+}
 
-		if _, err := util.GetSkillByName(skill); err != nil {
-			continue
-		}
+func (m MultiGoalGenerator) Name() string {
+	return m.Game.GeneratorName("multi")
+}
 
-		queryPrefix := url.QueryEscape(skill) + "="
-		if buf.Len() > 0 {
-			buf.WriteByte('&')
-		}
-		buf.WriteString(queryPrefix)
-		buf.WriteString(url.QueryEscape(goal))
-	}
+func (m MultiGoalGenerator) Url() string {
+	return m.Game.Route("/multi/:username")
+}
 
-	hideUsername := form.Get("hide")
-	if hideUsername == "on" && util.AesKey != nil && len(util.AesKey) > 0 {
-		name, err := util.Encrypt(username)
-		if err == nil {
-			name = "_" + name
-		}
-		username = name
+func (m MultiGoalGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Multiple skill goals in one",
+		Description: "Progress bars for up to five skill goals",
+		Game:        m.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(m.Game),
+			{
+				Name:        "goals",
+				Type:        generators.ParamSkillGoals,
+				Label:       "Goals",
+				Description: "Skills and their level or xp goals",
+				Max:         maxGoals,
+			},
+			generators.VirtualParam(),
+			generators.ETAParam(),
+			generators.RateParam(),
+			{
+				Name:        animateParam,
+				Type:        generators.ParamBool,
+				Label:       "Animate",
+				Description: "Show one goal at a time in an animated GIF",
+			},
+			{
+				Name:        delayParam,
+				Type:        generators.ParamNumber,
+				Label:       "Frame delay",
+				Description: "Milliseconds every goal of the animation is shown",
+				Min:         minDelay,
+				Max:         maxDelay,
+				Default:     strconv.Itoa(defaultDelay),
+			},
+			{
+				Name:        loopParam,
+				Type:        generators.ParamNumber,
+				Label:       "Loops",
+				Description: "Times the animation is played, 0 plays it forever",
+				Min:         0,
+				Max:         maxLoops,
+				Default:     "0",
+			},
+			generators.ScaleParam(),
+		},
+		Example: "/assets/img/multi_example.png",
 	}
-
-	hash := buf.String()
-	imageUrl := fmt.Sprintf("/multi/%s?%s", username, hash)
-	util.ServeResultPage(writer, imageUrl)
 }
 
 // Parse the request into a signature request
-func (m MultiGoalGenerator) ParseSignatureRequest(c web.C, r *http.Request) (util.ParsedSignatureRequest, error) {
-	req := util.NewSignatureRequest()
+func (m MultiGoalGenerator) ParseRequest(c web.C, r *http.Request) (MultiGoalRequest, error) {
+	var req MultiGoalRequest
 
 	username := util.ParseUsername(c.URLParams["username"])
-	usernameLength := len(username)
-	if !util.UsernameRegex.MatchString(username) {
-		return req, errors.New("invalid username entered, allowed characters: alphabets, numbers, _ and +")
-	}
-	if usernameLength < 1 || usernameLength > 12 {
-		return req, errors.New("username has to be between 1 and 12 characters long")
-	}
 
+	table := util.TableNormal
+	virtual, eta, rate := false, false, 0
+	animate, delay, loops := false, defaultDelay, 0
+	scale := 1
 	var goals []MultiGoal
 	params, _ := util.ParseQueryParameters(r.URL.RawQuery)
 	for _, param := range params {
-		skillName, skillGoal := param.Key, param.Value
-
-		// Make sure the skill is valid
-		skill, err := util.GetSkillByName(skillName)
-		if err != nil {
-			return req, errors.New("no skill found for the given skill name '" + skillName + "'")
-		}
-
-		// Check if goal has 'k' or 'm' suffix
-		goal, err := util.FromSuffixed(skillGoal)
-		if err != nil {
-			// Make sure the goal is numeric
-			goal, err = strconv.Atoi(skillGoal)
+		var err error
+		switch param.Key {
+		case util.HiscoreTableParam:
+			table, err = util.ParseHiscoreTable(m.Game, param.Value)
+		case util.VirtualParam:
+			virtual, err = util.ParseVirtual(param.Value)
+		case util.ETAParam:
+			eta, err = util.ParseFlag(util.ETAParam, param.Value)
+		case util.RateParam:
+			rate, err = util.ParseRate(param.Value)
+		case util.ScaleParam:
+			scale, err = util.ParseScale(param.Value)
+		case animateParam:
+			animate, err = util.ParseFlag(animateParam, param.Value)
+		case delayParam:
+			if delay, err = strconv.Atoi(param.Value); err != nil {
+				err = errors.New("invalid frame delay entered, make sure it is numeric")
+			}
+		case loopParam:
+			if loops, err = strconv.Atoi(param.Value); err != nil {
+				err = errors.New("invalid number of loops entered, make sure it is numeric")
+			}
+		default:
+			var goal MultiGoal
+			if goal, err = m.parseGoal(param.Key, param.Value); err == nil {
+				goals = append(goals, goal)
+			}
 		}
 		if err != nil {
-			return req, errors.New("invalid goal entered for " + skillName + ", make sure it is numeric or has 'k'/'m' suffix")
+			return req, err
 		}
+	}
 
-		// Make sure the goal is within valid bounds
-		if goal < 0 || goal > 200000000 {
-			return req, errors.New("invalid level/xp goal entered, make sure it 0-200,000,000")
-		}
+	return MultiGoalRequest{
+		Username: username,
+		Goals:    goals,
+		Table:    table,
+		Virtual:  virtual,
+		ETA:      eta,
+		Rate:     rate,
+		Animate:  animate,
+		Delay:    delay,
+		Loops:    loops,
+		Scale:    scale,
+	}, nil
+}
 
-		// Switch the goal type if the goal exceeds the maximum skill level
-		goalType := util.GetGoalType(skill, goal)
+// Parse a skill name and goal query parameter pair
+func (m MultiGoalGenerator) parseGoal(skillName, skillGoal string) (MultiGoal, error) {
+	var goal MultiGoal
 
-		goals = append(goals, MultiGoal{skill, goal, goalType})
+	// Make sure the skill is valid
+	skill, err := m.Game.GetSkillByName(skillName)
+	if err != nil {
+		return goal, errors.New("no skill found for the given skill name '" + skillName + "'")
 	}
 
-	req.AddProperty("username", username)
-	req.AddProperty("goals", goals)
-	return req, nil
+	// Check if goal has 'k' or 'm' suffix
+	value, err := util.FromSuffixed(skillGoal)
+	if err != nil {
+		// Make sure the goal is numeric
+		value, err = strconv.Atoi(skillGoal)
+	}
+	if err != nil {
+		return goal, errors.New("invalid goal entered for " + skillName + ", make sure it is numeric or has 'k'/'m' suffix")
+	}
+
+	// Switch the goal type if the goal exceeds the maximum skill level
+	goalType := util.GetGoalType(skill, value)
+
+	return MultiGoal{skill, value, goalType}, nil
 }
 
-func drawBar(img draw.Image, percent, x, y, width, height int) {
+func drawBar(img draw.Image, percent, x, y, width, height, scale int) {
 	greenWidth := int(float64(width) * (float64(percent) / 100.0))
 
 	red := color.RGBA{R: 160, G: 0, B: 0, A: 255}
-	redBar := image.Rect(x, y, width, y+height)
+	redBar := util.ScaleRect(image.Rect(x, y, width, y+height), scale)
 	green := color.RGBA{R: 0, G: 160, B: 0, A: 255}
-	greenBar := image.Rect(x, y, x+greenWidth, y+height)
+	greenBar := util.ScaleRect(image.Rect(x, y, x+greenWidth, y+height), scale)
 
 	draw.Draw(img, redBar, &image.Uniform{red}, image.ZP, draw.Src)
 	draw.Draw(img, greenBar, &image.Uniform{green}, image.ZP, draw.Src)
 }
 
 // Load base image to memory
-func loadBaseImage(goals int) *image.RGBA {
-	baseImage := image.NewRGBA(image.Rect(0, 0, baseWidth, baseHeight*goals+bottomPadding))
+func loadBaseImage(goals, scale int) *image.RGBA {
+	baseImage := image.NewRGBA(util.ScaleRect(image.Rect(0, 0, baseWidth, baseHeight*goals+bottomPadding), scale))
 	black := color.RGBA{R: 0, G: 0, B: 0, A: 255}
 	draw.Draw(baseImage, baseImage.Bounds(), &image.Uniform{black}, image.ZP, draw.Src)
 	return baseImage
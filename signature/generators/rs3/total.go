@@ -0,0 +1,170 @@
+package rs3
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zenazn/goji/web"
+	"net/http"
+	"github.com/cubeee/go-sig/signature/generators"
+	"github.com/cubeee/go-sig/signature/util"
+	"strings"
+)
+
+// Goal that stands for the max cape, every skill at its highest real level
+const maxGoal = "max"
+
+// TotalGenerator shows the total level, total xp and overall rank in the box style along with the
+// progress towards a total level or total xp goal
+type TotalGenerator struct {
+	Game  *util.Game
+	Stats util.StatsProvider
+}
+
+type TotalRequest struct {
+	// Game the goal is validated against
+	Game     *util.Game
+	Username string
+	Goal     int
+	GoalType util.GoalType
+	Table    util.HiscoreTable
+	Scale    int
+}
+
+func (r TotalRequest) Validate() error {
+	if err := util.ValidateUsername(r.Username); err != nil {
+		return err
+	}
+	if r.Goal < 1 || r.Goal > r.Game.TotalXPMax() {
+		return fmt.Errorf("the goal has to be between 1 and %s", util.Format(r.Game.TotalXPMax()))
+	}
+	if r.GoalType == util.GoalLevel && r.Goal > r.Game.TotalLevelMax() {
+		return fmt.Errorf("the total level goal can't be higher than %d", r.Game.TotalLevelMax())
+	}
+	return nil
+}
+
+func (r TotalRequest) Hash() string {
+	hash := fmt.Sprintf("%s-%d-%s", r.Username, r.Goal, r.Table)
+	if r.Scale > 1 {
+		hash += fmt.Sprintf("-%dx", r.Scale)
+	}
+	return hash
+}
+
+func NewTotalGenerator(game *util.Game, stats util.StatsProvider) *TotalGenerator {
+	return &TotalGenerator{Game: game, Stats: stats}
+}
+
+func (t TotalGenerator) CreateSignature(req TotalRequest) (util.Signature, error) {
+	stats, err := t.Stats.GetStats(util.Player{Name: req.Username, Game: t.Game.Mode, Table: req.Table})
+	if err != nil {
+		var s util.Signature
+		return s, fmt.Errorf("Failed to fetch stats for %s: %w", req.Username, err)
+	}
+	overall := util.GetStatBySkill(stats, t.Game.Overall())
+
+	var title, remainderLabel string
+	var percent, remainder int
+	if req.GoalType == util.GoalXP {
+		title = fmt.Sprintf("Total level: %d", overall.Level)
+		remainderLabel = "XP left:"
+		remainder = req.Goal - overall.Xp
+		percent = int(float64(overall.Xp) / float64(req.Goal) * 100.0)
+	} else {
+		title = fmt.Sprintf("Total: %d/%d", overall.Level, req.Goal)
+		remainderLabel = "Levels left:"
+		remainder = req.Goal - overall.Level
+		percent = int(float64(overall.Level) / float64(req.Goal) * 100.0)
+	}
+	if remainder < 0 {
+		remainder = 0
+	}
+	if percent > 100 {
+		percent = 100
+	}
+
+	rank := "Unranked"
+	if overall.Rank > 0 {
+		rank = util.Format(overall.Rank)
+	}
+
+	sig := drawBox(title, []boxRow{
+		{"Total XP:", util.Format(overall.Xp)},
+		{"Rank:", rank},
+		{remainderLabel, util.Format(remainder)},
+	}, percent, req.Scale)
+
+	sig.Username = req.Username
+	return sig, nil
+}
+
+// Create a signature showing the message instead of the totals
+func (t TotalGenerator) CreateErrorSignature(message string) (util.Signature, error) {
+	return BoxGoalGenerator{}.CreateErrorSignature(message)
+}
+
+func (t TotalGenerator) Name() string {
+	return t.Game.GeneratorName("total")
+}
+
+func (t TotalGenerator) Url() string {
+	return t.Game.Route("/total/:username")
+}
+
+func (t TotalGenerator) Metadata() generators.Metadata {
+	return generators.Metadata{
+		Title:       "Total level",
+		Description: "Total level, total xp and overall rank with progress towards a total goal",
+		Game:        t.Game,
+		Params: []generators.Param{
+			generators.UsernameParam(),
+			generators.HiscoreTableParam(t.Game),
+			{
+				Name:        "goal",
+				// The level and xp masks of goal inputs can't take "max" or suffixed xp goals
+				Type:        generators.ParamText,
+				Label:       "Goal",
+				Description: "Total level or total xp goal such as 5.4b, defaults to the max cape",
+				Default:     maxGoal,
+			},
+			generators.ScaleParam(),
+		},
+		Example: "/assets/img/total_example.png",
+	}
+}
+
+// Parse the request into a signature request
+func (t TotalGenerator) ParseRequest(c web.C, r *http.Request) (TotalRequest, error) {
+	var req TotalRequest
+
+	query := r.URL.Query()
+	table, err := util.ParseHiscoreTable(t.Game, query.Get(util.HiscoreTableParam))
+	if err != nil {
+		return req, err
+	}
+
+	scale, err := util.ParseScale(query.Get(util.ScaleParam))
+	if err != nil {
+		return req, err
+	}
+
+	// Goals up to the highest total level are total level goals, the rest total xp goals
+	goal, goalType := t.Game.TotalLevelMax(), util.GoalLevel
+	if value := strings.ToLower(query.Get("goal")); value != "" && value != maxGoal {
+		if goal, err = util.FromSuffixed(value); err != nil {
+			return req, errors.New("invalid goal entered, make sure it is numeric or has 'k'/'m'/'b' suffix")
+		}
+		if goal > t.Game.TotalLevelMax() {
+			goalType = util.GoalXP
+		}
+	}
+
+	return TotalRequest{
+		Game:     t.Game,
+		Username: util.ParseUsername(c.URLParams["username"]),
+		Goal:     goal,
+		GoalType: goalType,
+		Table:    table,
+		Scale:    scale,
+	}, nil
+}
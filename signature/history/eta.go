@@ -0,0 +1,54 @@
+package history
+
+import (
+	"time"
+
+	"github.com/cubeee/go-sig/signature/util"
+)
+
+const (
+	// RateWindow is how far back the recorded history is read to find the recent xp rate
+	RateWindow = 7 * 24 * time.Hour
+	// Snapshots closer together than this don't give a meaningful rate
+	minRateSpan = time.Hour
+	// Estimates further away than this aren't shown
+	maxETA = 100 * 365 * 24 * time.Hour
+)
+
+// XPPerDay returns the xp per day the player has recently gained in the skill. The second return value is
+// false if there isn't enough history or no xp was gained.
+func (s *Store) XPPerDay(player util.Player, skill util.Skill, now time.Time) (float64, bool, error) {
+	snapshots, err := s.Snapshots(player, now.Add(-RateWindow), now)
+	if err != nil || len(snapshots) < 2 {
+		return 0, false, err
+	}
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	span := last.Time.Sub(first.Time)
+	if span < minRateSpan {
+		return 0, false, nil
+	}
+	gained := last.Xp[skill.Id] - first.Xp[skill.Id]
+	if gained <= 0 {
+		return 0, false, nil
+	}
+	return float64(gained) / span.Hours() * 24, true, nil
+}
+
+// ETA estimates the time it takes to gain the remaining xp at the player's recent rate, falling back to the given
+// xp per hour. The store may be nil in which case only the fallback rate is used.
+func (s *Store) ETA(player util.Player, skill util.Skill, remaining, fallbackPerHour int) (time.Duration, bool) {
+	perHour := float64(fallbackPerHour)
+	if s != nil {
+		if perDay, ok, err := s.XPPerDay(player, skill, time.Now()); err == nil && ok {
+			perHour = perDay / 24
+		}
+	}
+	if perHour <= 0 {
+		return 0, false
+	}
+	hours := float64(remaining) / perHour
+	if hours > maxETA.Hours() {
+		return 0, false
+	}
+	return time.Duration(hours * float64(time.Hour)), true
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cubeee/go-sig/signature/util"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Snapshot is the xp of every skill of a player at one point in time, the overall xp is kept under util.OverallId
+type Snapshot struct {
+	Time time.Time
+	Xp   map[int]int
+}
+
+// Store records stat snapshots of players in an embedded bbolt database. Every player has a bucket of their own
+// with the snapshots keyed by time, so the snapshots of a period can be read with a single cursor seek.
+type Store struct {
+	db        *bolt.DB
+	Retention time.Duration
+}
+
+var playersBucket = []byte("players")
+
+// Open opens or creates the database at the given path, snapshots older than the retention are removed by Prune
+func Open(path string, retention time.Duration) (*Store, error) {
+	db, err := bolt.Open(path, 0640, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(playersBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, Retention: retention}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record saves the xp of every stat as a snapshot taken at the given time
+func (s *Store) Record(player util.Player, stats map[int]util.Stat, at time.Time) error {
+	xp := make(map[int]int, len(stats))
+	for id, stat := range stats {
+		xp[id] = stat.Xp
+	}
+	value, err := json.Marshal(xp)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(playersBucket).CreateBucketIfNotExists(playerKey(player))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(timeKey(at), value)
+	})
+}
+
+// Snapshots returns the snapshots of the player taken between from and to, oldest first
+func (s *Store) Snapshots(player util.Player, from, to time.Time) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(playersBucket).Bucket(playerKey(player))
+		if bucket == nil {
+			return nil
+		}
+		end := timeKey(to)
+		cursor := bucket.Cursor()
+		for key, value := cursor.Seek(timeKey(from)); key != nil && bytes.Compare(key, end) <= 0; key, value = cursor.Next() {
+			snapshot, err := decodeSnapshot(key, value)
+			if err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+		}
+		return nil
+	})
+	return snapshots, err
+}
+
+// Prune removes the snapshots that are older than the retention, players left without snapshots are removed
+// altogether. Returns the number of removed snapshots.
+func (s *Store) Prune(now time.Time) (int, error) {
+	if s.Retention <= 0 {
+		return 0, nil
+	}
+	cutoff := timeKey(now.Add(-s.Retention))
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		players := tx.Bucket(playersBucket)
+		var empty [][]byte
+		err := players.ForEachBucket(func(name []byte) error {
+			bucket := players.Bucket(name)
+			cursor := bucket.Cursor()
+			for key, _ := cursor.First(); key != nil && bytes.Compare(key, cutoff) < 0; key, _ = cursor.First() {
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				removed++
+			}
+			if key, _ := cursor.First(); key == nil {
+				empty = append(empty, append([]byte(nil), name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range empty {
+			if err := players.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// Players are keyed by game, hiscore table and lowercased name as the hiscores ignore the case of names
+func playerKey(player util.Player) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s", util.Games[player.Game].Name, player.Table, strings.ToLower(player.Name)))
+}
+
+// Big endian unix nanoseconds sort in time order
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+func decodeSnapshot(key, value []byte) (Snapshot, error) {
+	snapshot := Snapshot{Time: time.Unix(0, int64(binary.BigEndian.Uint64(key)))}
+	err := json.Unmarshal(value, &snapshot.Xp)
+	return snapshot, err
+}
@@ -0,0 +1,30 @@
+package history
+
+import (
+	"log"
+	"time"
+
+	"github.com/cubeee/go-sig/signature/util"
+)
+
+// RecordingProvider records the stats returned by another provider in the history store. Failing to record
+// is only logged, the stats are returned either way.
+type RecordingProvider struct {
+	Provider util.StatsProvider
+	Store    *Store
+}
+
+func NewRecordingProvider(provider util.StatsProvider, store *Store) *RecordingProvider {
+	return &RecordingProvider{Provider: provider, Store: store}
+}
+
+func (p *RecordingProvider) GetStats(player util.Player) (map[int]util.Stat, error) {
+	stats, err := p.Provider.GetStats(player)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Store.Record(player, stats, time.Now()); err != nil {
+		log.Printf("Failed to record stats of %s: %v", player.Name, err)
+	}
+	return stats, nil
+}
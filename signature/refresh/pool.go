@@ -0,0 +1,73 @@
+package refresh
+
+import (
+	"log"
+	"sync"
+)
+
+// Pool runs refresh jobs on a fixed number of workers.
+// Jobs are identified by a key and a job is dropped if one with the same key is already queued or running.
+type Pool struct {
+	jobs    chan job
+	mutex   sync.Mutex
+	pending map[string]bool
+}
+
+type job struct {
+	key string
+	run func()
+}
+
+func NewPool(workers, queueSize int) *Pool {
+	p := &Pool{
+		jobs:    make(chan job, queueSize),
+		pending: make(map[string]bool),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues the job without blocking, returns false if the job was dropped
+// because of a duplicate key or a full queue
+func (p *Pool) Submit(key string, run func()) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.pending[key] {
+		return false
+	}
+	select {
+	case p.jobs <- job{key, run}:
+		p.pending[key] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Number of jobs waiting for a worker
+func (p *Pool) QueueDepth() int {
+	return len(p.jobs)
+}
+
+func (p *Pool) work() {
+	for j := range p.jobs {
+		p.run(j)
+	}
+}
+
+// Run a single job, a panicking job is logged so it doesn't take the worker down with it
+func (p *Pool) run(j job) {
+	defer func() {
+		p.mutex.Lock()
+		delete(p.pending, j.key)
+		p.mutex.Unlock()
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Refresh of %s panicked: %v", j.key, r)
+		}
+	}()
+	j.run()
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachedStore keeps the most recently used images of another store in memory, up to a total of MaxBytes.
+// Saved images are added to the cache right away so freshly rendered images are served from memory.
+type CachedStore struct {
+	Store    ImageStore
+	MaxBytes int64
+
+	hits   int64
+	misses int64
+
+	mutex sync.Mutex
+	size  int64
+	items map[string]*list.Element
+	order *list.List
+}
+
+// CacheStats holds the counters of a CachedStore, hits and misses are counted when loading images
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Items  int
+	Bytes  int64
+}
+
+type cachedImage struct {
+	data []byte
+	info ImageInfo
+}
+
+func NewCachedStore(store ImageStore, maxBytes int64) *CachedStore {
+	return &CachedStore{
+		Store:    store,
+		MaxBytes: maxBytes,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *CachedStore) Save(hash string, data []byte) error {
+	if err := c.Store.Save(hash, data); err != nil {
+		c.remove(hash)
+		return err
+	}
+	info := ImageInfo{Hash: hash, Size: int64(len(data)), ModTime: time.Now()}
+	c.add(cachedImage{data: data, info: info})
+	return nil
+}
+
+func (c *CachedStore) Load(hash string) ([]byte, ImageInfo, error) {
+	if image, ok := c.get(hash); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return image.data, image.info, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+	data, info, err := c.Store.Load(hash)
+	if err != nil {
+		return data, info, err
+	}
+	c.add(cachedImage{data: data, info: info})
+	return data, info, nil
+}
+
+func (c *CachedStore) Stat(hash string) (ImageInfo, error) {
+	if image, ok := c.get(hash); ok {
+		return image.info, nil
+	}
+	return c.Store.Stat(hash)
+}
+
+func (c *CachedStore) Delete(hash string) error {
+	c.remove(hash)
+	return c.Store.Delete(hash)
+}
+
+func (c *CachedStore) List() ([]ImageInfo, error) {
+	return c.Store.List()
+}
+
+func (c *CachedStore) Stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return CacheStats{
+		Hits:   atomic.LoadInt64(&c.hits),
+		Misses: atomic.LoadInt64(&c.misses),
+		Items:  len(c.items),
+		Bytes:  c.size,
+	}
+}
+
+func (c *CachedStore) get(hash string) (cachedImage, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	element, ok := c.items[hash]
+	if !ok {
+		return cachedImage{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(cachedImage), true
+}
+
+func (c *CachedStore) add(image cachedImage) {
+	size := int64(len(image.data))
+	if size > c.MaxBytes {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.items[image.info.Hash]; ok {
+		c.size -= int64(len(element.Value.(cachedImage).data))
+		element.Value = image
+		c.order.MoveToFront(element)
+	} else {
+		c.items[image.info.Hash] = c.order.PushFront(image)
+	}
+	c.size += size
+
+	// Evict the least recently used images until the cache fits
+	for c.size > c.MaxBytes {
+		oldest := c.order.Back()
+		evicted := c.order.Remove(oldest).(cachedImage)
+		delete(c.items, evicted.info.Hash)
+		c.size -= int64(len(evicted.data))
+	}
+}
+
+func (c *CachedStore) remove(hash string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.items[hash]; ok {
+		c.order.Remove(element)
+		delete(c.items, hash)
+		c.size -= int64(len(element.Value.(cachedImage).data))
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Prefix of the temporary files images are written to before being renamed into place
+	tempPrefix = ".tmp-"
+	// Temporary files older than this are considered orphaned
+	tempMaxAge = time.Minute
+)
+
+// FileStore keeps the images as files in a directory on the local disk.
+// Images are written to a temporary file first and renamed into place so readers never see partial images.
+type FileStore struct {
+	Root string
+}
+
+func NewFileStore(root string) (*FileStore, error) {
+	if err := os.MkdirAll(root, 0740); err != nil {
+		return nil, err
+	}
+	return &FileStore{Root: root}, nil
+}
+
+func (f *FileStore) Save(hash string, data []byte) error {
+	temp, err := ioutil.TempFile(f.Root, tempPrefix+hash+"-")
+	if err != nil {
+		return err
+	}
+	tempName := temp.Name()
+
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempName, 0640)
+	}
+	if err == nil {
+		err = os.Rename(tempName, f.path(hash))
+	}
+	if err != nil {
+		os.Remove(tempName)
+	}
+	return err
+}
+
+// CleanTemp removes orphaned temporary files and returns the number of removed files
+func (f *FileStore) CleanTemp() (int, error) {
+	files, err := ioutil.ReadDir(f.Root)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, file := range files {
+		if !isTemp(file.Name()) || time.Since(file.ModTime()) < tempMaxAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(f.Root, file.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (f *FileStore) Load(hash string) ([]byte, ImageInfo, error) {
+	var info ImageInfo
+	file, err := os.Open(f.path(hash))
+	if err != nil {
+		return nil, info, translateError(err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, info, err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, info, err
+	}
+	return data, fileInfo(hash, stat), nil
+}
+
+func (f *FileStore) Stat(hash string) (ImageInfo, error) {
+	stat, err := os.Stat(f.path(hash))
+	if err != nil {
+		return ImageInfo{}, translateError(err)
+	}
+	return fileInfo(hash, stat), nil
+}
+
+func (f *FileStore) Delete(hash string) error {
+	if err := os.Remove(f.path(hash)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) List() ([]ImageInfo, error) {
+	files, err := ioutil.ReadDir(f.Root)
+	if err != nil {
+		return nil, err
+	}
+	var images []ImageInfo
+	for _, file := range files {
+		if !file.Mode().IsRegular() || isTemp(file.Name()) {
+			continue
+		}
+		images = append(images, fileInfo(file.Name(), file))
+	}
+	return images, nil
+}
+
+func (f *FileStore) path(hash string) string {
+	return filepath.Join(f.Root, hash)
+}
+
+func isTemp(name string) bool {
+	return strings.HasPrefix(name, tempPrefix)
+}
+
+func fileInfo(hash string, stat os.FileInfo) ImageInfo {
+	return ImageInfo{Hash: hash, Size: stat.Size(), ModTime: stat.ModTime()}
+}
+
+func translateError(err error) error {
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Janitor removes images that haven't been served within the retention period and evicts the
+// least recently served images while the total size of the store exceeds the quota.
+// Access times are kept in memory and merged with the ones saved in the store on every collection, so they
+// survive restarts and are shared by every instance using the same store. Images without a recorded access
+// use their modification time instead.
+type Janitor struct {
+	Store     ImageStore
+	Retention time.Duration
+	// Quota is the maximum total size of the images in bytes, 0 disables the quota
+	Quota int64
+	// OnRemove is called with the hash of every removed image, it may be nil
+	OnRemove func(hash string)
+
+	mutex    sync.Mutex
+	accessed map[string]time.Time
+}
+
+// Report describes the outcome of a single collection
+type Report struct {
+	Expired   int
+	Evicted   int
+	Freed     int64
+	Remaining int
+	Size      int64
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("removed %d expired and %d evicted images, freed %d bytes, %d images (%d bytes) remain",
+		r.Expired, r.Evicted, r.Freed, r.Remaining, r.Size)
+}
+
+// Name the access times are saved under in the store, it isn't a valid signature hash
+const accessTimesName = ".access-times"
+
+func NewJanitor(store ImageStore, retention time.Duration, quota int64) *Janitor {
+	return &Janitor{
+		Store:     store,
+		Retention: retention,
+		Quota:     quota,
+		accessed:  make(map[string]time.Time),
+	}
+}
+
+// Touch records that the image was just served
+func (j *Janitor) Touch(hash string) {
+	j.mutex.Lock()
+	j.accessed[hash] = time.Now()
+	j.mutex.Unlock()
+}
+
+func (j *Janitor) lastAccess(image ImageInfo) time.Time {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if accessed, ok := j.accessed[image.Hash]; ok && accessed.After(image.ModTime) {
+		return accessed
+	}
+	return image.ModTime
+}
+
+// Collect removes the expired images first and then evicts the least recently served images until
+// the store fits in the quota
+func (j *Janitor) Collect() (Report, error) {
+	var report Report
+	if err := j.loadAccessTimes(); err != nil {
+		return report, err
+	}
+	images, err := j.Store.List()
+	if err != nil {
+		return report, err
+	}
+
+	type entry struct {
+		info     ImageInfo
+		accessed time.Time
+	}
+	now := time.Now()
+	var kept []entry
+	for _, image := range images {
+		if image.Hash == accessTimesName {
+			continue
+		}
+		accessed := j.lastAccess(image)
+		if now.Sub(accessed) > j.Retention {
+			if err := j.remove(image.Hash); err != nil {
+				return report, err
+			}
+			report.Expired++
+			report.Freed += image.Size
+			continue
+		}
+		kept = append(kept, entry{image, accessed})
+		report.Size += image.Size
+	}
+
+	if j.Quota > 0 && report.Size > j.Quota {
+		sort.Slice(kept, func(a, b int) bool {
+			return kept[a].accessed.Before(kept[b].accessed)
+		})
+		for len(kept) > 0 && report.Size > j.Quota {
+			image := kept[0].info
+			if err := j.remove(image.Hash); err != nil {
+				return report, err
+			}
+			kept = kept[1:]
+			report.Evicted++
+			report.Freed += image.Size
+			report.Size -= image.Size
+		}
+	}
+	report.Remaining = len(kept)
+
+	// Forget access times of images that no longer exist
+	existing := make(map[string]bool, len(kept))
+	for _, e := range kept {
+		existing[e.info.Hash] = true
+	}
+	j.mutex.Lock()
+	for hash := range j.accessed {
+		if !existing[hash] {
+			delete(j.accessed, hash)
+		}
+	}
+	j.mutex.Unlock()
+
+	return report, j.saveAccessTimes()
+}
+
+// Merge the access times saved by earlier collections, of this or another instance, into the recorded ones
+func (j *Janitor) loadAccessTimes() error {
+	data, _, err := j.sharedStore().Load(accessTimesName)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var saved map[string]int64
+	if err := json.Unmarshal(data, &saved); err != nil {
+		// Damaged access times are overwritten by the next save
+		return nil
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	for hash, unix := range saved {
+		if accessed := time.Unix(unix, 0); accessed.After(j.accessed[hash]) {
+			j.accessed[hash] = accessed
+		}
+	}
+	return nil
+}
+
+func (j *Janitor) saveAccessTimes() error {
+	j.mutex.Lock()
+	saved := make(map[string]int64, len(j.accessed))
+	for hash, accessed := range j.accessed {
+		saved[hash] = accessed.Unix()
+	}
+	j.mutex.Unlock()
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	return j.sharedStore().Save(accessTimesName, data)
+}
+
+// The access times are read past the memory cache, other instances may have saved newer ones
+func (j *Janitor) sharedStore() ImageStore {
+	if cached, ok := j.Store.(*CachedStore); ok {
+		return cached.Store
+	}
+	return j.Store
+}
+
+func (j *Janitor) remove(hash string) error {
+	if err := j.Store.Delete(hash); err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	delete(j.accessed, hash)
+	j.mutex.Unlock()
+	if j.OnRemove != nil {
+		j.OnRemove(hash)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the images in memory, they are lost when the process exits
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	images map[string]memoryImage
+}
+
+type memoryImage struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{images: make(map[string]memoryImage)}
+}
+
+func (m *MemoryStore) Save(hash string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.images[hash] = memoryImage{data: append([]byte(nil), data...), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemoryStore) Load(hash string) ([]byte, ImageInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	image, ok := m.images[hash]
+	if !ok {
+		return nil, ImageInfo{}, ErrNotFound
+	}
+	return image.data, image.info(hash), nil
+}
+
+func (m *MemoryStore) Stat(hash string) (ImageInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	image, ok := m.images[hash]
+	if !ok {
+		return ImageInfo{}, ErrNotFound
+	}
+	return image.info(hash), nil
+}
+
+func (m *MemoryStore) Delete(hash string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.images, hash)
+	return nil
+}
+
+func (m *MemoryStore) List() ([]ImageInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	images := make([]ImageInfo, 0, len(m.images))
+	for hash, image := range m.images {
+		images = append(images, image.info(hash))
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Hash < images[j].Hash
+	})
+	return images, nil
+}
+
+func (i memoryImage) info(hash string) ImageInfo {
+	return ImageInfo{Hash: hash, Size: int64(len(i.data)), ModTime: i.modTime}
+}
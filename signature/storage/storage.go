@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("image not found")
+
+// ImageInfo describes a stored image
+type ImageInfo struct {
+	Hash    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ImageStore keeps the encoded signature images, identified by their finalized hash
+type ImageStore interface {
+	Save(hash string, data []byte) error
+	// Load returns ErrNotFound if no image exists with the given hash
+	Load(hash string) ([]byte, ImageInfo, error)
+	// Stat returns ErrNotFound if no image exists with the given hash
+	Stat(hash string) (ImageInfo, error)
+	Delete(hash string) error
+	List() ([]ImageInfo, error)
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// Run the image store through saving, loading, listing and deleting images
+func testImageStore(t *testing.T, store ImageStore) {
+	t.Helper()
+
+	if _, _, err := store.Load("missing"); err != ErrNotFound {
+		t.Fatalf("Load() of a missing image error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Stat("missing"); err != ErrNotFound {
+		t.Fatalf("Stat() of a missing image error = %v, want ErrNotFound", err)
+	}
+
+	images := map[string][]byte{
+		"box-Zezima-attack-99": []byte("first image"),
+		"multi-Zezima.webp":    []byte("second image"),
+		"total-Zezima-2000-2x": []byte("third"),
+	}
+	for hash, data := range images {
+		if err := store.Save(hash, data); err != nil {
+			t.Fatalf("Save(%s) error = %v", hash, err)
+		}
+	}
+
+	for hash, want := range images {
+		data, info, err := store.Load(hash)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", hash, err)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("Load(%s) = %q, want %q", hash, data, want)
+		}
+		if info.Hash != hash || info.Size != int64(len(want)) || info.ModTime.IsZero() {
+			t.Errorf("Load(%s) info = %+v", hash, info)
+		}
+
+		info, err = store.Stat(hash)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", hash, err)
+		}
+		if info.Hash != hash || info.Size != int64(len(want)) || info.ModTime.IsZero() {
+			t.Errorf("Stat(%s) = %+v", hash, info)
+		}
+	}
+
+	// Saving again replaces the image
+	if err := store.Save("total-Zezima-2000-2x", []byte("replaced")); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if data, _, err := store.Load("total-Zezima-2000-2x"); err != nil || string(data) != "replaced" {
+		t.Errorf("Load() after replacing = %q, %v", data, err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(list) != len(images) {
+		t.Fatalf("List() = %+v, want %d images", list, len(images))
+	}
+	for _, info := range list {
+		if _, ok := images[info.Hash]; !ok {
+			t.Errorf("List() contains unknown image %s", info.Hash)
+		}
+	}
+
+	if err := store.Delete("multi-Zezima.webp"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err := store.Load("multi-Zezima.webp"); err != ErrNotFound {
+		t.Errorf("Load() of a deleted image error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete("multi-Zezima.webp"); err != nil {
+		t.Errorf("Delete() of a missing image error = %v", err)
+	}
+	if list, err = store.List(); err != nil || len(list) != len(images)-1 {
+		t.Errorf("List() after deleting = %+v, %v", list, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testImageStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testImageStore(t, store)
+}
+
+func TestCachedStore(t *testing.T) {
+	cache := NewCachedStore(NewMemoryStore(), 1024)
+	testImageStore(t, cache)
+
+	stats := cache.Stats()
+	if stats.Hits == 0 || stats.Misses == 0 {
+		t.Errorf("Stats() = %+v, want hits and misses", stats)
+	}
+}
+
+func TestJanitorQuota(t *testing.T) {
+	store := NewMemoryStore()
+	for _, hash := range []string{"a", "b", "c"} {
+		if err := store.Save(hash, make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	janitor := NewJanitor(store, time.Hour, 20)
+	var removed []string
+	janitor.OnRemove = func(hash string) {
+		removed = append(removed, hash)
+	}
+	janitor.Touch("b")
+	janitor.Touch("c")
+
+	report, err := janitor.Collect()
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if report.Evicted != 1 || report.Remaining != 2 || report.Size != 20 {
+		t.Errorf("Collect() = %+v, want 1 evicted and 2 remaining", report)
+	}
+	if len(removed) != 1 || removed[0] != "a" {
+		t.Errorf("removed %v, want the least recently served image a", removed)
+	}
+	if _, err := store.Stat(accessTimesName); err != nil {
+		t.Errorf("access times weren't saved: %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package util
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+	"time"
+)
+
+// Number of colors a GIF palette can hold
+const paletteSize = 256
+
+// NewAnimation converts the frames to a GIF showing every frame for the given delay. The animation is played
+// the given number of times, 0 plays it forever. All frames share one palette so the file stays small.
+func NewAnimation(frames []image.Image, delay time.Duration, loops int) *gif.GIF {
+	palette := SharedPalette(frames)
+	centiseconds := int(delay / (10 * time.Millisecond))
+
+	animation := &gif.GIF{
+		Config: image.Config{ColorModel: palette},
+	}
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(bounds, palette)
+		draw.Draw(paletted, bounds, frame, bounds.Min, draw.Src)
+
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, centiseconds)
+		if bounds.Dx() > animation.Config.Width {
+			animation.Config.Width = bounds.Dx()
+		}
+		if bounds.Dy() > animation.Config.Height {
+			animation.Config.Height = bounds.Dy()
+		}
+	}
+
+	// The GIF loop count is the number of repeats after the first play, -1 plays the animation once
+	switch loops {
+	case 0:
+		animation.LoopCount = 0
+	case 1:
+		animation.LoopCount = -1
+	default:
+		animation.LoopCount = loops - 1
+	}
+	return animation
+}
+
+// SharedPalette returns the most used colors of the frames. Signatures are mostly flat colors with anti-aliased
+// text, so the rarest colors are left out and drawn with the nearest color of the palette instead.
+func SharedPalette(frames []image.Image) color.Palette {
+	counts := make(map[color.RGBA]int)
+	for _, frame := range frames {
+		bounds := frame.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				counts[color.RGBAModel.Convert(frame.At(x, y)).(color.RGBA)]++
+			}
+		}
+	}
+
+	colors := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	// Ties are broken by the color value so the same frames always give the same palette
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+		return packRGBA(colors[i]) < packRGBA(colors[j])
+	})
+	if len(colors) > paletteSize {
+		colors = colors[:paletteSize]
+	}
+
+	palette := make(color.Palette, len(colors))
+	for i, c := range colors {
+		palette[i] = c
+	}
+	return palette
+}
+
+func packRGBA(c color.RGBA) uint32 {
+	return uint32(c.R)<<24 | uint32(c.G)<<16 | uint32(c.B)<<8 | uint32(c.A)
+}
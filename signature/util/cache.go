@@ -0,0 +1,90 @@
+package util
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsCache keeps the stats returned by another provider in memory for the given time to live.
+// Concurrent lookups for the same player share a single request to the underlying provider.
+type StatsCache struct {
+	Provider StatsProvider
+	TTL      time.Duration
+
+	mutex     sync.Mutex
+	entries   map[Player]*statsEntry
+	lastSweep time.Time
+}
+
+type statsEntry struct {
+	done    chan struct{}
+	stats   map[int]Stat
+	err     error
+	fetched time.Time
+}
+
+func NewStatsCache(provider StatsProvider, ttl time.Duration) *StatsCache {
+	return &StatsCache{
+		Provider:  provider,
+		TTL:       ttl,
+		entries:   make(map[Player]*statsEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (c *StatsCache) GetStats(player Player) (map[int]Stat, error) {
+	key := player
+	key.Name = strings.ToLower(key.Name)
+
+	c.mutex.Lock()
+	c.sweep()
+	if entry, ok := c.entries[key]; ok {
+		select {
+		case <-entry.done:
+			if entry.err == nil && time.Since(entry.fetched) < c.TTL {
+				c.mutex.Unlock()
+				return entry.stats, nil
+			}
+		default:
+			// Another request is already fetching the stats, wait for it instead
+			c.mutex.Unlock()
+			<-entry.done
+			return entry.stats, entry.err
+		}
+	}
+	entry := &statsEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mutex.Unlock()
+
+	entry.stats, entry.err = c.Provider.GetStats(player)
+	entry.fetched = time.Now()
+	close(entry.done)
+
+	if entry.err != nil {
+		// Don't keep failed lookups around, the next request retries
+		c.mutex.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mutex.Unlock()
+	}
+	return entry.stats, entry.err
+}
+
+// Remove expired entries, called with the mutex held
+func (c *StatsCache) sweep() {
+	if time.Since(c.lastSweep) < c.TTL {
+		return
+	}
+	c.lastSweep = time.Now()
+	for key, entry := range c.entries {
+		select {
+		case <-entry.done:
+			if time.Since(entry.fetched) >= c.TTL {
+				delete(c.entries, key)
+			}
+		default:
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package util
+
+import (
+	"image"
+	"image/draw"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// TextDrawer draws strings on an image using the given font, size and color
+type TextDrawer struct {
+	drawer *font.Drawer
+	ascent int
+	scale  int
+}
+
+func NewTextDrawer(img draw.Image, src image.Image, f *truetype.Font, size, dpi float64) *TextDrawer {
+	return NewScaledTextDrawer(img, src, f, size, dpi, 1)
+}
+
+// NewScaledTextDrawer creates a drawer for images rendered at a scale factor. Positions are given unscaled and
+// both the positions and the font size are multiplied by the scale.
+func NewScaledTextDrawer(img draw.Image, src image.Image, f *truetype.Font, size, dpi float64, scale int) *TextDrawer {
+	return &TextDrawer{
+		drawer: &font.Drawer{
+			Dst: img,
+			Src: src,
+			Face: truetype.NewFace(f, &truetype.Options{
+				Size:    size * float64(scale),
+				DPI:     dpi,
+				Hinting: font.HintingFull,
+			}),
+		},
+		ascent: int(size * dpi / 72.0),
+		scale:  scale,
+	}
+}
+
+// Draw a string with its top left corner at the given position
+func (t *TextDrawer) DrawString(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x*t.scale), y)
+}
+
+// Draw a string with its top right corner at the given position
+func (t *TextDrawer) DrawRightAligned(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x*t.scale)-t.drawer.MeasureString(str), y)
+}
+
+// Draw a string horizontally centered on the given position
+func (t *TextDrawer) DrawCentered(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x*t.scale)-t.drawer.MeasureString(str)/2, y)
+}
+
+// Width of the string in unscaled pixels
+func (t *TextDrawer) Measure(str string) int {
+	return (t.drawer.MeasureString(str).Ceil() + t.scale - 1) / t.scale
+}
+
+func (t *TextDrawer) drawFixed(str string, x fixed.Int26_6, y int) {
+	t.drawer.Dot = fixed.Point26_6{
+		X: x,
+		Y: fixed.I((y + t.ascent) * t.scale),
+	}
+	t.drawer.DrawString(str)
+}
+
+// ScaleRect multiplies the corners of an unscaled rectangle by the scale
+func ScaleRect(r image.Rectangle, scale int) image.Rectangle {
+	return image.Rect(r.Min.X*scale, r.Min.Y*scale, r.Max.X*scale, r.Max.Y*scale)
+}
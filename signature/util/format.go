@@ -0,0 +1,140 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// ImageFormat is the file format a signature is encoded in
+type ImageFormat string
+
+const (
+	FormatPNG  ImageFormat = "png"
+	FormatWebP ImageFormat = "webp"
+	FormatJPEG ImageFormat = "jpg"
+	FormatSVG  ImageFormat = "svg"
+)
+
+const jpegQuality = 90
+
+var (
+	// Formats in the order they are preferred when an Accept header likes several of them equally
+	ImageFormats = []ImageFormat{FormatPNG, FormatWebP, FormatJPEG, FormatSVG}
+
+	formatExtensions = map[string]ImageFormat{
+		".png":  FormatPNG,
+		".webp": FormatWebP,
+		".jpg":  FormatJPEG,
+		".jpeg": FormatJPEG,
+		".svg":  FormatSVG,
+	}
+	formatTypes = map[ImageFormat]string{
+		FormatPNG:  "image/png",
+		FormatWebP: "image/webp",
+		FormatJPEG: "image/jpeg",
+		FormatSVG:  "image/svg+xml",
+	}
+)
+
+// ContentType returns the media type of the format
+func (f ImageFormat) ContentType() string {
+	return formatTypes[f]
+}
+
+// FormatFromExtension returns the format of a file extension such as ".webp"
+func FormatFromExtension(ext string) (ImageFormat, bool) {
+	format, ok := formatExtensions[strings.ToLower(ext)]
+	return format, ok
+}
+
+// NegotiateFormat picks the format from an Accept header. PNG is used when the header is empty or doesn't
+// prefer any other format, so browsers that accept everything keep getting PNGs.
+func NegotiateFormat(accept string) ImageFormat {
+	best, bestQuality := FormatPNG, 0.0
+	for _, format := range ImageFormats {
+		if quality := acceptQuality(accept, format.ContentType()); quality > bestQuality {
+			best, bestQuality = format, quality
+		}
+	}
+	return best
+}
+
+// Returns the quality the Accept header gives the media type, the most specific matching range counts
+func acceptQuality(accept, mediaType string) float64 {
+	quality, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		var matched int
+		switch {
+		case mediaRange == mediaType:
+			matched = 2
+		case mediaRange == "image/*" && strings.HasPrefix(mediaType, "image/"):
+			matched = 1
+		case mediaRange == "*/*":
+			matched = 0
+		default:
+			continue
+		}
+		if matched < specificity {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		quality, specificity = q, matched
+	}
+	return quality
+}
+
+// Encode writes the signature in the given format. Animated signatures are always GIFs and signatures without
+// a vector version, error signatures included, are embedded in the SVG as a PNG.
+func Encode(w io.Writer, sig Signature, format ImageFormat) error {
+	if sig.Animation != nil {
+		return gif.EncodeAll(w, sig.Animation)
+	}
+	switch format {
+	case FormatWebP:
+		return nativewebp.Encode(w, sig.Image, nil)
+	case FormatJPEG:
+		return jpeg.Encode(w, sig.Image, &jpeg.Options{Quality: jpegQuality})
+	case FormatSVG:
+		var vector *SVG
+		if sig.Vector != nil {
+			vector = sig.Vector()
+		} else {
+			vector = NewSVG(sig.Image.Bounds().Dx(), sig.Image.Bounds().Dy())
+			if err := vector.Image(sig.Image, 0, 0); err != nil {
+				return err
+			}
+		}
+		_, err := w.Write(vector.Bytes())
+		return err
+	case FormatPNG, "":
+		return png.Encode(w, sig.Image)
+	}
+	return errors.New("unknown image format '" + string(format) + "'")
+}
+
+// DetectContentType returns the media type of an encoded signature
+func DetectContentType(data []byte) string {
+	if bytes.HasPrefix(data, []byte("<svg")) {
+		return FormatSVG.ContentType()
+	}
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// GoalProgress is the progress of a skill towards a level or xp goal
+type GoalProgress struct {
+	CurrentLevel int
+	CurrentXP    int
+	GoalLevel    int
+	GoalXP       int
+	// Remainder is the xp left to the goal, zero once the goal is reached
+	Remainder int
+	Percent   int
+}
+
+// Progress calculates the progress of the stat towards the goal, levels are virtual if asked for or if
+// the goal is only reachable with virtual levels
+func Progress(stat Stat, goal int, goalType GoalType, virtual bool) GoalProgress {
+	virtual = virtual || IsVirtualGoal(stat.Skill, goal, goalType)
+
+	progress := GoalProgress{
+		CurrentLevel: LevelFromXP(stat.Skill, stat.Xp, virtual),
+		CurrentXP:    stat.Xp,
+	}
+	if goalType == GoalXP {
+		progress.GoalXP = goal
+		progress.Remainder = goal - stat.Xp
+	} else {
+		progress.GoalXP = XPForLevel(stat.Skill, goal)
+		progress.Remainder = XPToLevel(stat.Skill, stat.Xp, goal)
+	}
+	progress.GoalLevel = LevelFromXP(stat.Skill, progress.GoalXP, virtual)
+	if progress.Remainder < 0 {
+		progress.Remainder = 0
+	}
+	// Goals such as level 1 need no xp at all and are reached before dividing by zero
+	if stat.Xp >= progress.GoalXP {
+		progress.Percent = 100
+	} else {
+		progress.Percent = int(float64(stat.Xp) / float64(progress.GoalXP) * 100.0)
+	}
+	if progress.Percent < 0 {
+		progress.Percent = 0
+	}
+	return progress
+}
+
+// FormatDuration formats a time to goal with its two most significant units, e.g. 3d 4h or 5h 12m
+func FormatDuration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	days, hours := minutes/(24*60), minutes/60%24
+	minutes %= 60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return "< 1m"
+}
@@ -7,18 +7,44 @@ import (
 	"strings"
 )
 
+type GameMode int
+
+const (
+	RS3 GameMode = iota
+	OSRS
+)
+
 type Skill struct {
 	Name string
 	Id   int
+	Game GameMode
+}
+
+// Game holds the skill set, limits and hiscores location of a game mode
+type Game struct {
+	Mode        GameMode
+	Name        string
+	Title       string
+	SkillNames  []string
+	Skills      map[int]Skill
+	// LevelMax is the highest virtual level, the limit of level goals
+	LevelMax    int
+	XPMax       int
+	hiscore     string
+	routePrefix string
 }
 
 const (
 	LevelMax          = 126
+	XPMax             = 200000000
 	InventionLevelMax = 150
 	InventionId       = 26
+	DungeoneeringId   = 24
+	// OverallId is the id of the overall stat holding the total level and xp
+	OverallId = -1
 )
 
-// todo: shortened names, no params, total level, front page form
+// todo: no params
 var (
 	SkillNames = []string{
 		"Attack",        //  0
@@ -49,8 +75,90 @@ var (
 		"Divination",    // 25
 		"Invention",     // 26
 	}
-	ExpThresholds          []int
-	Skills                 = map[int]Skill{}
+	OSRSSkillNames = []string{
+		"Attack",       //  0
+		"Defence",      //  1
+		"Strength",     //  2
+		"Hitpoints",    //  3
+		"Ranged",       //  4
+		"Prayer",       //  5
+		"Magic",        //  6
+		"Cooking",      //  7
+		"Woodcutting",  //  8
+		"Fletching",    //  9
+		"Fishing",      // 10
+		"Firemaking",   // 11
+		"Crafting",     // 12
+		"Smithing",     // 13
+		"Mining",       // 14
+		"Herblore",     // 15
+		"Agility",      // 16
+		"Thieving",     // 17
+		"Slayer",       // 18
+		"Farming",      // 19
+		"Runecraft",    // 20
+		"Hunter",       // 21
+		"Construction", // 22
+	}
+	// Abbreviations used where the full skill names don't fit
+	ShortSkillNames = map[string]string{
+		"Attack":        "Att",
+		"Defence":       "Def",
+		"Strength":      "Str",
+		"Constitution":  "HP",
+		"Hitpoints":     "HP",
+		"Ranged":        "Range",
+		"Prayer":        "Pray",
+		"Magic":         "Mage",
+		"Cooking":       "Cook",
+		"Woodcutting":   "WC",
+		"Fletching":     "Fletch",
+		"Fishing":       "Fish",
+		"Firemaking":    "FM",
+		"Crafting":      "Craft",
+		"Smithing":      "Smith",
+		"Mining":        "Mine",
+		"Herblore":      "Herb",
+		"Agility":       "Agil",
+		"Thieving":      "Thiev",
+		"Slayer":        "Slay",
+		"Farming":       "Farm",
+		"Runecrafting":  "RC",
+		"Runecraft":     "RC",
+		"Hunter":        "Hunt",
+		"Construction":  "Con",
+		"Summoning":     "Summ",
+		"Dungeoneering": "Dung",
+		"Divination":    "Div",
+		"Invention":     "Inv",
+	}
+	ExpThresholds []int
+	Skills        = map[int]Skill{}
+	RS3Game       = &Game{
+		Mode:       RS3,
+		Name:       "rs3",
+		Title:      "RuneScape",
+		SkillNames: SkillNames,
+		Skills:     Skills,
+		LevelMax:   LevelMax,
+		XPMax:      XPMax,
+		hiscore:    "hiscore",
+	}
+	OSRSGame = &Game{
+		Mode:        OSRS,
+		Name:        "osrs",
+		Title:       "Old School RuneScape",
+		SkillNames:  OSRSSkillNames,
+		Skills:      map[int]Skill{},
+		LevelMax:    LevelMax,
+		XPMax:       XPMax,
+		hiscore:     "hiscore_oldschool",
+		routePrefix: "/osrs",
+	}
+	Games = map[GameMode]*Game{
+		RS3:  RS3Game,
+		OSRS: OSRSGame,
+	}
 	InventionExpThresholds = []int{
 		0, 830, 1861, 2902, 3980, 5126, 6380, 7787, 9400, 11275,
 		13605, 16372, 19656, 23546, 28134, 33520, 39809, 47109, 55535,
@@ -74,8 +182,10 @@ var (
 )
 
 func init() {
-	for idx := 0; idx < len(SkillNames); idx++ {
-		Skills[idx] = Skill{SkillNames[idx], idx}
+	for _, game := range Games {
+		for idx := 0; idx < len(game.SkillNames); idx++ {
+			game.Skills[idx] = Skill{game.SkillNames[idx], idx, game.Mode}
+		}
 	}
 
 	ExpThresholds = make([]int, LevelMax+1)
@@ -89,10 +199,23 @@ func init() {
 	}
 }
 
-func GetSkillByName(name string) (Skill, error) {
+// Route prefixes the given path with the game's route prefix
+func (g *Game) Route(path string) string {
+	return g.routePrefix + path
+}
+
+// GeneratorName qualifies a generator name with the game name, RS3 names are left as-is
+func (g *Game) GeneratorName(name string) string {
+	if g.Mode == RS3 {
+		return name
+	}
+	return g.Name + "-" + name
+}
+
+func (g *Game) GetSkillByName(name string) (Skill, error) {
 	name = strings.ToLower(name)
 	var s Skill
-	for _, skill := range Skills {
+	for _, skill := range g.Skills {
 		if strings.ToLower(skill.Name) == name {
 			s = skill
 			return s, nil
@@ -101,12 +224,67 @@ func GetSkillByName(name string) (Skill, error) {
 	return s, errors.New("no skill found with the given name")
 }
 
-func GetSkillById(id int) (Skill, error) {
+func (g *Game) GetSkillById(id int) (Skill, error) {
 	var s Skill
-	if id < 0 || id >= len(Skills) {
-		return s, errors.New(fmt.Sprintf("Id out of bounds, 0-%d expected", len(Skills)))
+	if id < 0 || id >= len(g.Skills) {
+		return s, errors.New(fmt.Sprintf("Id out of bounds, 0-%d expected", len(g.Skills)))
+	}
+	return g.Skills[id], nil
+}
+
+// Overall returns the pseudo skill of the game's overall stat
+func (g *Game) Overall() Skill {
+	return Skill{"Overall", OverallId, g.Mode}
+}
+
+// TotalLevelMax returns the total level of a player who has every skill at its highest real level
+func (g *Game) TotalLevelMax() int {
+	total := 0
+	for _, skill := range g.Skills {
+		total += RealLevelMax(skill)
 	}
-	return Skills[id], nil
+	return total
+}
+
+// TotalXPMax returns the total xp of a player who has every skill at maximum xp
+func (g *Game) TotalXPMax() int {
+	return len(g.Skills) * g.XPMax
+}
+
+// ShortName returns the abbreviated name of the skill
+func (s Skill) ShortName() string {
+	if short, ok := ShortSkillNames[s.Name]; ok {
+		return short
+	}
+	return s.Name
+}
+
+func GetSkillByName(name string) (Skill, error) {
+	return RS3Game.GetSkillByName(name)
+}
+
+func GetSkillById(id int) (Skill, error) {
+	return RS3Game.GetSkillById(id)
+}
+
+func isInvention(skill Skill) bool {
+	return skill.Game == RS3 && skill.Id == InventionId
+}
+
+// MaxLevel returns the highest virtual level of the skill, also the highest level goal accepted for it
+func MaxLevel(skill Skill) int {
+	if isInvention(skill) {
+		return InventionLevelMax
+	}
+	return Games[skill.Game].LevelMax
+}
+
+// RealLevelMax returns the highest level of the skill that counts towards the total level
+func RealLevelMax(skill Skill) int {
+	if skill.Game == RS3 && (skill.Id == DungeoneeringId || skill.Id == InventionId) {
+		return 120
+	}
+	return 99
 }
 
 func XPToLevel(skill Skill, currentXp, targetLevel int) int {
@@ -115,24 +293,31 @@ func XPToLevel(skill Skill, currentXp, targetLevel int) int {
 }
 
 func XPForLevel(skill Skill, level int) int {
-	if skill.Id == InventionId {
+	if isInvention(skill) {
 		return InventionExpThresholds[level-1]
 	} else {
 		return ExpThresholds[level-1]
 	}
 }
 
-func LevelFromXP(skill Skill, xp int) int {
-	var maxLevel int
-	var xpTable []int
-	if skill.Id == InventionId {
-		maxLevel = InventionLevelMax
-		xpTable = InventionExpThresholds
-	} else {
-		maxLevel = LevelMax
-		xpTable = ExpThresholds
+// LevelFromXP returns the level of the skill at the given xp. Real levels stop at 99 or 120, virtual levels
+// keep going up to the level of 200m xp, e.g. 126 for most skills and 150 for Invention.
+func LevelFromXP(skill Skill, xp int, virtual bool) int {
+	if virtual {
+		return levelFromXP(skill, xp, MaxLevel(skill))
+	}
+	return levelFromXP(skill, xp, RealLevelMax(skill))
+}
+
+func xpTable(skill Skill) []int {
+	if isInvention(skill) {
+		return InventionExpThresholds
 	}
+	return ExpThresholds
+}
 
+func levelFromXP(skill Skill, xp, maxLevel int) int {
+	xpTable := xpTable(skill)
 	for level := 1; level <= maxLevel; level++ {
 		if xp < xpTable[level-1] {
 			return level - 1
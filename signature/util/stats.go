@@ -3,56 +3,228 @@ package util
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Stat struct {
 	Skill Skill
+	// Rank is -1 when the player isn't ranked in the skill
+	Rank  int
+	Level int
 	Xp    int
 }
 
-func GetStats(username string) (map[int]Stat, error) {
-	stats := map[int]Stat{}
+type HiscoreTable int
+
+const (
+	TableNormal HiscoreTable = iota
+	TableIronman
+	TableHardcore
+	TableSeasonal
+)
+
+// Name of the query parameter used to select the hiscore table
+const HiscoreTableParam = "hiscore"
+
+var (
+	HiscoreTableNames = []string{
+		"normal",   // TableNormal
+		"ironman",  // TableIronman
+		"hardcore", // TableHardcore
+		"seasonal", // TableSeasonal
+	}
+	hiscoreTableSuffixes = []string{
+		"",
+		"_ironman",
+		"_hardcore_ironman",
+		"_seasonal",
+	}
+)
+
+func (t HiscoreTable) String() string {
+	return HiscoreTableNames[t]
+}
+
+// HiscoreTables returns the names of the hiscore tables of the game. The RS3 seasonal boards use a different
+// api than the rest of the hiscores, so only OSRS has a seasonal table.
+func (g *Game) HiscoreTables() []string {
+	if g.Mode == OSRS {
+		return HiscoreTableNames
+	}
+	return HiscoreTableNames[:TableSeasonal]
+}
+
+// ParseHiscoreTable returns the hiscore table of the game with the given name, an empty name selects the
+// normal table
+func ParseHiscoreTable(game *Game, name string) (HiscoreTable, error) {
+	if name == "" {
+		return TableNormal, nil
+	}
+	name = strings.ToLower(name)
+	for idx, tableName := range HiscoreTableNames {
+		if tableName != name {
+			continue
+		}
+		if idx >= len(game.HiscoreTables()) {
+			return TableNormal, fmt.Errorf("the %s hiscores are not available for %s", name, game.Title)
+		}
+		return HiscoreTable(idx), nil
+	}
+	return TableNormal, errors.New("unknown hiscore table, allowed values: " + strings.Join(game.HiscoreTables(), ", "))
+}
+
+// Player identifies whose stats to look up, from which game and hiscore table
+type Player struct {
+	Name  string
+	Game  GameMode
+	Table HiscoreTable
+}
+
+// StatsProvider supplies the current stats of a player to the generators
+type StatsProvider interface {
+	GetStats(player Player) (map[int]Stat, error)
+}
 
-	url := fmt.Sprintf("http://services.runescape.com/m=hiscore/index_lite.ws?player=%s", username)
+var (
+	ErrPlayerNotFound      = errors.New("player not found")
+	ErrHiscoresUnavailable = errors.New("hiscores unavailable")
+	ErrRateLimited         = errors.New("rate limited by the hiscores")
+	ErrMalformedResponse   = errors.New("malformed hiscores response")
+)
+
+// Upper limit for the size of a hiscores response
+const maxHiscoresBodySize = 64 * 1024
+
+// HiscoresProvider fetches stats from the RuneScape hiscores.
+// Failed requests are retried with a jittered exponential backoff unless the failure is permanent.
+type HiscoresProvider struct {
+	Client     *http.Client
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func NewHiscoresProvider(timeout time.Duration, retries int) *HiscoresProvider {
+	return &HiscoresProvider{
+		Client:     &http.Client{Timeout: timeout},
+		Retries:    retries,
+		Backoff:    250 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+	}
+}
+
+func (h *HiscoresProvider) GetStats(player Player) (map[int]Stat, error) {
+	game := Games[player.Game]
+	url := fmt.Sprintf("http://services.runescape.com/m=%s%s/index_lite.ws?player=%s",
+		game.hiscore, hiscoreTableSuffixes[player.Table], neturl.QueryEscape(player.Name))
+
+	var err error
+	for attempt := 0; attempt <= h.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(h.backoff(attempt))
+		}
+
+		var stats map[int]Stat
+		stats, err = h.fetch(url, game)
+		if err == nil || !isRetryable(err) {
+			return stats, err
+		}
+	}
+	return nil, err
+}
+
+func (h *HiscoresProvider) fetch(url string, game *Game) (map[int]Stat, error) {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return stats, err
+		return nil, err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := h.Client.Do(req)
 	if err != nil {
-		return stats, err
+		return nil, fmt.Errorf("%w: %v", ErrHiscoresUnavailable, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return stats, errors.New(fmt.Sprintf("HTTP request failed, received status code %d", resp.StatusCode))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrPlayerNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: received status code %d", ErrHiscoresUnavailable, resp.StatusCode)
 	}
 
-	body, _ := ioutil.ReadAll(resp.Body)
-	content := strings.Split(string(body), "\n")
-	for i := 1; i <= len(Skills); i++ {
-		parts := strings.Split(content[i], ",")
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHiscoresBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrHiscoresUnavailable, err)
+	}
+	return parseStats(string(body), game)
+}
+
+// Delay before the given retry attempt, the delay doubles on every attempt and half of it is random
+func (h *HiscoresProvider) backoff(attempt int) time.Duration {
+	delay := h.Backoff << uint(attempt-1)
+	if delay <= 0 || delay > h.MaxBackoff {
+		delay = h.MaxBackoff
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrHiscoresUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+// Parse the lite hiscores format, one "rank,level,xp" line per skill after the overall line
+func parseStats(body string, game *Game) (map[int]Stat, error) {
+	stats := map[int]Stat{}
+
+	content := strings.Split(strings.TrimSpace(body), "\n")
+	if len(content) < len(game.Skills)+1 {
+		return nil, fmt.Errorf("%w: expected at least %d lines, got %d", ErrMalformedResponse,
+			len(game.Skills)+1, len(content))
+	}
+	// The first line holds the overall rank, total level and total xp, the skills follow in id order
+	for i := 0; i <= len(game.Skills); i++ {
+		parts := strings.Split(strings.TrimSpace(content[i]), ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("%w: expected 3 fields on line %d, got %d", ErrMalformedResponse, i+1, len(parts))
+		}
 
 		id := i - 1
-		skill, err := GetSkillById(id)
-		if err != nil {
-			continue
+		skill := game.Overall()
+		if id != OverallId {
+			var err error
+			if skill, err = game.GetSkillById(id); err != nil {
+				continue
+			}
 		}
-		xp, err := strconv.Atoi(parts[2])
-		if err != nil {
-			xp = 0
+		var values [3]int
+		for j, part := range parts {
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid number on line %d", ErrMalformedResponse, i+1)
+			}
+			values[j] = value
 		}
+		rank, level, xp := values[0], values[1], values[2]
 		if xp < 0 {
 			xp = 0
 		}
+		if level < 1 {
+			level = 1
+		}
 
 		stats[id] = Stat{
 			Skill: skill,
+			Rank:  rank,
+			Level: level,
 			Xp:    xp,
 		}
 	}
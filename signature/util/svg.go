@@ -0,0 +1,209 @@
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// SVG is the vector version of a signature. Text is drawn as glyph outlines so the SVG looks the same
+// everywhere without embedding the font.
+type SVG struct {
+	width, height int
+	// Scale multiplies the size the SVG is shown at, the drawing itself is always in unscaled coordinates
+	Scale int
+	body  bytes.Buffer
+}
+
+func NewSVG(width, height int) *SVG {
+	return &SVG{width: width, height: height, Scale: 1}
+}
+
+// Rect fills a rectangle with the color
+func (s *SVG) Rect(x, y, width, height int, c color.Color) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	fmt.Fprintf(&s.body, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x, y, width, height, svgColor(c))
+}
+
+// Image embeds a raster image with its top left corner at the given position. The image stays pixelated when
+// the SVG is shown larger, like the scaled PNGs.
+func (s *SVG) Image(img image.Image, x, y int) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	fmt.Fprintf(&s.body, `<image x="%d" y="%d" width="%d" height="%d" style="image-rendering:pixelated" href="data:image/png;base64,%s"/>`,
+		x, y, bounds.Dx(), bounds.Dy(), base64.StdEncoding.EncodeToString(buf.Bytes()))
+	return nil
+}
+
+// Bytes returns the SVG document
+func (s *SVG) Bytes() []byte {
+	var doc bytes.Buffer
+	fmt.Fprintf(&doc, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		s.width*s.Scale, s.height*s.Scale, s.width, s.height)
+	doc.Write(s.body.Bytes())
+	doc.WriteString("</svg>\n")
+	return doc.Bytes()
+}
+
+// SVGTextDrawer draws strings on an SVG, it is positioned like TextDrawer so both can share coordinates
+type SVGTextDrawer struct {
+	svg    *SVG
+	font   *truetype.Font
+	color  string
+	scale  fixed.Int26_6
+	ascent int
+	glyph  truetype.GlyphBuf
+}
+
+func NewSVGTextDrawer(svg *SVG, src image.Image, f *truetype.Font, size, dpi float64) *SVGTextDrawer {
+	return &SVGTextDrawer{
+		svg:    svg,
+		font:   f,
+		color:  svgColor(src.At(0, 0)),
+		scale:  fixed.Int26_6(size * dpi / 72.0 * 64),
+		ascent: int(size * dpi / 72.0),
+	}
+}
+
+// Draw a string with its top left corner at the given position
+func (t *SVGTextDrawer) DrawString(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x), y)
+}
+
+// Draw a string with its top right corner at the given position
+func (t *SVGTextDrawer) DrawRightAligned(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x)-t.measure(str), y)
+}
+
+// Draw a string horizontally centered on the given position
+func (t *SVGTextDrawer) DrawCentered(str string, x, y int) {
+	t.drawFixed(str, fixed.I(x)-t.measure(str)/2, y)
+}
+
+func (t *SVGTextDrawer) measure(str string) fixed.Int26_6 {
+	var width fixed.Int26_6
+	prev, hasPrev := truetype.Index(0), false
+	for _, r := range str {
+		index := t.font.Index(r)
+		if hasPrev {
+			width += t.font.Kern(t.scale, prev, index)
+		}
+		width += t.font.HMetric(t.scale, index).AdvanceWidth
+		prev, hasPrev = index, true
+	}
+	return width
+}
+
+func (t *SVGTextDrawer) drawFixed(str string, x fixed.Int26_6, y int) {
+	var path strings.Builder
+	baseline := fixed.I(y + t.ascent)
+	prev, hasPrev := truetype.Index(0), false
+	for _, r := range str {
+		index := t.font.Index(r)
+		if hasPrev {
+			x += t.font.Kern(t.scale, prev, index)
+		}
+		if err := t.glyph.Load(t.font, t.scale, index, font.HintingNone); err == nil {
+			start := 0
+			for _, end := range t.glyph.Ends {
+				writeContour(&path, t.glyph.Points[start:end], x, baseline)
+				start = end
+			}
+		}
+		x += t.font.HMetric(t.scale, index).AdvanceWidth
+		prev, hasPrev = index, true
+	}
+	if path.Len() > 0 {
+		fmt.Fprintf(&t.svg.body, `<path fill="%s" d="%s"/>`, t.color, path.String())
+	}
+}
+
+// Write a TrueType contour as a path. Contours are made of quadratic curves where two off curve points
+// in a row have an implied on curve point between them.
+func writeContour(path *strings.Builder, points []truetype.Point, x, baseline fixed.Int26_6) {
+	if len(points) == 0 {
+		return
+	}
+	type point struct{ x, y float64 }
+	at := func(p truetype.Point) point {
+		// Glyph y coordinates grow upwards
+		return point{float64(x+p.X) / 64, float64(baseline-p.Y) / 64}
+	}
+	mid := func(a, b point) point {
+		return point{(a.x + b.x) / 2, (a.y + b.y) / 2}
+	}
+	onCurve := func(p truetype.Point) bool {
+		return p.Flags&0x01 != 0
+	}
+
+	// Start from the first on curve point, or between the last and the first point if there are none
+	first := -1
+	for i, p := range points {
+		if onCurve(p) {
+			first = i
+			break
+		}
+	}
+	var start point
+	var rest []truetype.Point
+	if first < 0 {
+		start = mid(at(points[len(points)-1]), at(points[0]))
+		rest = points
+	} else {
+		start = at(points[first])
+		rest = append(append([]truetype.Point(nil), points[first+1:]...), points[:first]...)
+	}
+
+	path.WriteString("M" + svgPoint(start.x, start.y))
+	var control *point
+	for _, p := range rest {
+		current := at(p)
+		if onCurve(p) {
+			if control != nil {
+				path.WriteString("Q" + svgPoint(control.x, control.y) + " " + svgPoint(current.x, current.y))
+				control = nil
+			} else {
+				path.WriteString("L" + svgPoint(current.x, current.y))
+			}
+			continue
+		}
+		if control != nil {
+			implied := mid(*control, current)
+			path.WriteString("Q" + svgPoint(control.x, control.y) + " " + svgPoint(implied.x, implied.y))
+		}
+		control = &current
+	}
+	if control != nil {
+		path.WriteString("Q" + svgPoint(control.x, control.y) + " " + svgPoint(start.x, start.y))
+	}
+	path.WriteString("Z")
+}
+
+func svgPoint(x, y float64) string {
+	return svgNumber(x) + "," + svgNumber(y)
+}
+
+// Two decimals are plenty at signature sizes
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func svgColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
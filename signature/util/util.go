@@ -7,27 +7,36 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/flosch/pongo2"
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"image"
+	"image/gif"
 	"io"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 	"github.com/cubeee/go-sig/signature"
 )
 
 var (
 	md5Hash        = md5.New()
 	UsernameRegex  = regexp.MustCompile("^_?[a-zA-Z0-9-_+]+$")
-	resultTemplate = pongo2.Must(pongo2.FromFile("resources/templates/result.tpl"))
+	resultTemplate = pongo2.Must(pongo2.FromFile(ResourcePath("resources/templates/result.tpl")))
 	AesKey []byte
 )
 
+// Returned for requests that can't be parsed into a signature request
+var ErrInvalidRequest = errors.New("invalid request")
+
 type GoalType int
 
 const (
@@ -38,27 +47,11 @@ const (
 type Signature struct {
 	Username string
 	Image    image.Image
-}
-
-type SignatureRequest struct {
-	Req  ParsedSignatureRequest
-	Hash string
-}
-
-type ParsedSignatureRequest struct {
-	properties map[string]interface{}
-}
-
-func (s ParsedSignatureRequest) AddProperty(name string, value interface{}) {
-	s.properties[name] = value
-}
-
-func (s ParsedSignatureRequest) GetProperty(name string) interface{} {
-	return s.properties[name]
-}
-
-func NewSignatureRequest() ParsedSignatureRequest {
-	return ParsedSignatureRequest{properties: make(map[string]interface{})}
+	// Animation replaces the image of animated signatures, they are saved as GIFs
+	Animation *gif.GIF
+	// Vector draws the SVG version of the image, it is only called when the signature is encoded as an SVG and
+	// left nil by generators that only draw raster images
+	Vector func() *SVG
 }
 
 func ServeResultPage(writer http.ResponseWriter, url string) {
@@ -70,6 +63,17 @@ func ServeResultPage(writer http.ResponseWriter, url string) {
 	}
 }
 
+// Show the reason a signature couldn't be created from the submitted form
+func ServeFormErrorPage(writer http.ResponseWriter, message string) {
+	writer.WriteHeader(http.StatusBadRequest)
+	if err := resultTemplate.ExecuteWriter(pongo2.Context{
+		"error":    message,
+		"base_url": vars.Protocol + "://" + vars.VirtualHost,
+	}, writer); err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func GetMD5(text string) string {
 	md5Hash = md5.New()
 	md5Hash.Write([]byte(text))
@@ -95,6 +99,30 @@ func Format(n int) string {
 	}
 }
 
+// ValidateUsername makes sure the username is a valid RuneScape display name
+func ValidateUsername(username string) error {
+	usernameLength := len(username)
+	if !UsernameRegex.MatchString(username) {
+		return errors.New("invalid username entered, allowed characters: alphabets, numbers, _ and +")
+	}
+	if usernameLength < 1 || usernameLength > 12 {
+		return errors.New("username has to be between 1 and 12 characters long")
+	}
+	return nil
+}
+
+// ValidateGoal makes sure a level goal is a valid level for the skill and an xp goal doesn't exceed the xp limit
+func ValidateGoal(skill Skill, goal int, goalType GoalType) error {
+	xpMax := Games[skill.Game].XPMax
+	if goalType == GoalLevel && (goal < 1 || goal > MaxLevel(skill)) {
+		return errors.New(fmt.Sprintf("invalid level goal entered for %s, make sure it is 1-%d", skill.Name, MaxLevel(skill)))
+	}
+	if goal < 0 || goal > xpMax {
+		return errors.New(fmt.Sprintf("invalid level/xp goal entered, make sure it 0-%s", Format(xpMax)))
+	}
+	return nil
+}
+
 func ParseUsername(username string) string {
 	if AesKey != nil && strings.Index(username, "_") == 0 {
 		nameHex := username[1:]
@@ -107,6 +135,18 @@ func ParseUsername(username string) string {
 	return username
 }
 
+// HideUsername encrypts the username for signature urls if an encryption key is set
+func HideUsername(username string) string {
+	if len(AesKey) == 0 {
+		return username
+	}
+	name, err := Encrypt(username)
+	if err != nil {
+		return username
+	}
+	return "_" + name
+}
+
 func Encrypt(str string) (string, error) {
 	block, err := aes.NewCipher(AesKey)
 	if err != nil {
@@ -151,27 +191,41 @@ func decrypt(str string) (plaintext string, err error) {
 	return
 }
 
+// Suffixed numbers above this are rejected before they can overflow
+const maxSuffixed = 1e15
+
+var suffixMultipliers = map[byte]float64{
+	'k': 1000,
+	'm': 1000000,
+	'b': 1000000000,
+}
+
+// FromSuffixed parses numbers such as 500k, 13m or 5.4b, plain numbers are accepted as-is
 func FromSuffixed(value string) (int, error) {
-	lastCharacter := string(value[len(value)-1])
-	if lastCharacter != "k" && lastCharacter != "m" {
+	if value == "" {
+		return 0, errors.New("empty number")
+	}
+	// Lowercasing the whole value can change its length, so only the last byte is looked at
+	suffix := value[len(value)-1]
+	if suffix >= utf8.RuneSelf {
+		return 0, errors.New("invalid suffix")
+	}
+	if 'A' <= suffix && suffix <= 'Z' {
+		suffix += 'a' - 'A'
+	}
+	multiplier, ok := suffixMultipliers[suffix]
+	if !ok {
 		if val, err := strconv.Atoi(value); err == nil {
 			return val, nil
 		} else {
 			return 0, errors.New("invalid suffix")
 		}
 	}
-	noSuffix := value[:len(value)-1]
-	number, err := strconv.Atoi(noSuffix)
-	if lastCharacter == "k" && err == nil {
-		return number * 1000, nil
-	} else if lastCharacter == "m" && err == nil {
-		return number * 1000000, nil
+	number, err := strconv.ParseFloat(value[:len(value)-1], 64)
+	if err != nil || math.IsNaN(number) || number < 0 || number*multiplier > maxSuffixed {
+		return 0, errors.New("failed to parse suffixed number")
 	}
-	val, err := strconv.Atoi(value)
-	if err != nil {
-		val = 0
-	}
-	return val, errors.New("failed to parse suffixed number")
+	return int(math.Round(number * multiplier)), nil
 }
 
 // Parse query parameters and return them in the right order
@@ -214,9 +268,29 @@ type QueryParameter struct {
 	Value string
 }
 
+// ResourcePath finds a file under resources/ from the working directory or its parents, so the packages
+// can also be loaded from their own directory like go test does
+func ResourcePath(name string) string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+	for {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return name
+		}
+		dir = parent
+	}
+}
+
 // Load font(s) to memory
 func LoadFont(fontFile string) *truetype.Font {
-	fontBytes, err := ioutil.ReadFile(fontFile)
+	fontBytes, err := ioutil.ReadFile(ResourcePath(fontFile))
 	if err != nil {
 		panic(err)
 	}
@@ -229,8 +303,96 @@ func LoadFont(fontFile string) *truetype.Font {
 
 func GetGoalType(skill Skill, goal int) GoalType {
 	goalType := GoalLevel
-	if (skill.Id == InventionId && goal > InventionLevelMax) || (skill.Id != InventionId && goal > LevelMax) {
+	if goal > MaxLevel(skill) {
 		goalType = GoalXP
 	}
 	return goalType
 }
+
+// Query parameters shared by the goal generators
+const (
+	// VirtualParam switches a signature to virtual levels
+	VirtualParam = "virtual"
+	// ETAParam adds the estimated time to goal to a signature
+	ETAParam = "eta"
+	// RateParam is the xp per hour used for the estimate when there's no recorded history
+	RateParam = "rate"
+	// ScaleParam renders a signature at a multiple of its size for high-DPI screens
+	ScaleParam = "scale"
+)
+
+// MaxScale is the largest scale factor a signature can be rendered at
+const MaxScale = 3
+
+// ParseVirtual reads the virtual level flag, an empty value leaves it off
+func ParseVirtual(value string) (bool, error) {
+	return ParseFlag(VirtualParam, value)
+}
+
+// ParseFlag reads a boolean query parameter, an empty value leaves it off
+func ParseFlag(name, value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "false", "0":
+		return false, nil
+	case "true", "1":
+		return true, nil
+	}
+	return false, errors.New(name + " has to be true or false")
+}
+
+// ParseRate reads an xp per hour rate, 'k' and 'm' suffixes are allowed and an empty value means no rate
+func ParseRate(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	rate, err := FromSuffixed(value)
+	if err != nil {
+		return 0, errors.New("invalid xp rate entered, make sure it is numeric or has 'k'/'m' suffix")
+	}
+	return rate, nil
+}
+
+// ValidateRate makes sure an xp per hour rate is possible to reach
+func ValidateRate(rate int) error {
+	if rate < 0 || rate > XPMax {
+		return errors.New(fmt.Sprintf("invalid xp rate entered, make sure it is 0-%s", Format(XPMax)))
+	}
+	return nil
+}
+
+// ParseScale reads a scale factor such as "2" or "2x", an empty value means the normal size
+func ParseScale(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	scale, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(value), "x"))
+	if err != nil || scale < 1 || scale > MaxScale {
+		return 0, fmt.Errorf("invalid scale entered, make sure it is 1-%d", MaxScale)
+	}
+	return scale, nil
+}
+
+// IsVirtualGoal tells whether the goal can only be shown with virtual levels
+func IsVirtualGoal(skill Skill, goal int, goalType GoalType) bool {
+	if goalType == GoalLevel {
+		return goal > RealLevelMax(skill)
+	}
+	return goal > XPForLevel(skill, RealLevelMax(skill))
+}
+
+// ErrorStatus returns the HTTP status code and a short message describing the error on an error signature
+func ErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		return http.StatusBadRequest, "Invalid signature"
+	case errors.Is(err, ErrPlayerNotFound):
+		return http.StatusNotFound, "Player not found"
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests, "Hiscores busy"
+	case errors.Is(err, ErrHiscoresUnavailable):
+		return http.StatusServiceUnavailable, "Hiscores unavailable"
+	case errors.Is(err, ErrMalformedResponse):
+		return http.StatusBadGateway, "Invalid hiscores data"
+	}
+	return http.StatusInternalServerError, "Something went wrong"
+}
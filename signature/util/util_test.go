@@ -0,0 +1,61 @@
+package util
+
+import "testing"
+
+func TestFromSuffixed(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"123", 123, false},
+		{"500k", 500000, false},
+		{"13M", 13000000, false},
+		{"5.4b", 5400000000, false},
+		{"", 0, true},
+		{"k", 0, true},
+		{"5x", 0, true},
+		{"-5k", 0, true},
+		{"5ẞ", 0, true},
+		{"ẞ", 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := FromSuffixed(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FromSuffixed(%q) error = %v, wantErr %v", test.value, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("FromSuffixed(%q) = %d, want %d", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestProgressPercent(t *testing.T) {
+	attack, err := GetSkillByName("attack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		xp       int
+		goal     int
+		goalType GoalType
+		want     int
+	}{
+		{"level 1 without xp", 0, 1, GoalLevel, 100},
+		{"level 1 with xp", 500, 1, GoalLevel, 100},
+		{"halfway", 500, 1000, GoalXP, 50},
+		{"past the goal", 2000, 1000, GoalXP, 100},
+		{"no xp", 0, 1000, GoalXP, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Progress(Stat{Skill: attack, Xp: test.xp}, test.goal, test.goalType, false).Percent
+			if got != test.want {
+				t.Errorf("Percent = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,21 @@
 package vars
 
 var (
-	VirtualHost    = "sig.scapelog.com"
-	ImageRoot      = "signatures"
-	PublicPath     = "resources/public/"
-	UpdateInterval = 10.0
-	Protocol       = "https"
+	VirtualHost      = "sig.scapelog.com"
+	ImageRoot        = "signatures"
+	ImageStore       = "file"
+	PublicPath       = "resources/public/"
+	UpdateInterval   = 10.0
+	StatsCacheTTL    = 5.0
+	HiscoresTimeout  = 5.0
+	HiscoresRetries  = 2
+	RefreshWorkers   = 4
+	RefreshQueue     = 256
+	ImageRetention   = 720.0
+	ImageQuota       = 0.0
+	CollectInterval  = 60.0
+	ImageCacheSize   = 64.0
+	HistoryPath      = "history.db"
+	HistoryRetention = 90.0
+	Protocol         = "https"
 )
@@ -1,25 +1,33 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
 	"fmt"
-	"image"
-	"image/png"
 	"log"
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"path"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/flosch/pongo2"
 	"github.com/zenazn/goji"
 	"github.com/zenazn/goji/web"
 
+	"github.com/cubeee/go-sig/signature/api"
 	"github.com/cubeee/go-sig/signature/generators"
 	"github.com/cubeee/go-sig/signature/generators/rs3"
+	"github.com/cubeee/go-sig/signature/generators/rs3/grid"
 	"github.com/cubeee/go-sig/signature/generators/rs3/multi"
+	"github.com/cubeee/go-sig/signature/history"
+	"github.com/cubeee/go-sig/signature/refresh"
+	"github.com/cubeee/go-sig/signature/storage"
 	"github.com/cubeee/go-sig/signature/util"
 	"github.com/cubeee/go-sig/signature"
 )
@@ -31,14 +39,22 @@ func (NullWriter) Write([]byte) (int, error) {
 }
 
 var (
-	indexTemplate  = pongo2.Must(pongo2.FromFile("resources/templates/index.tpl"))
+	indexTemplate = pongo2.Must(pongo2.FromFile("resources/templates/index.tpl"))
+	refreshPool   *refresh.Pool
+	imageStore    storage.ImageStore
+	cachedStore   *storage.CachedStore
+	janitor       *storage.Janitor
+	historyStore  *history.Store
+	registry      generators.Registry
+	// Hashes of signatures whose last background refresh failed
+	failedRefreshes sync.Map
 )
 
 func init() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 }
 
-func createAndSaveSignature(req util.SignatureRequest, generator generators.BaseGenerator) error {
+func createAndSaveSignature(req generators.SignatureRequest, generator generators.BaseGenerator) error {
 	// Create the signature image
 	sig, err := generator.CreateSignature(req.Req)
 	if err != nil {
@@ -46,109 +62,249 @@ func createAndSaveSignature(req util.SignatureRequest, generator generators.Base
 	}
 
 	// note: queue saving if it causes performance issues?
-	// Save the image to disk with the given hash as the file name
-	saveImage(req.Hash, sig.Image)
-	return nil
+	// Save the image with the given hash as the name
+	return saveImage(req.Hash, sig, req.Format)
 }
 
-// Save the image to disk with the given hash as the file name
-func saveImage(hash string, img image.Image) {
-	out, err := os.Create(vars.ImageRoot + "/" + hash)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	defer out.Close()
-	writer := bufio.NewWriter(out)
-	err = png.Encode(writer, img)
-	if err != nil {
-		log.Println(err)
-		return
-	}
-	err = writer.Flush()
-	if err != nil {
-		log.Println(err)
-		return
+// Save the signature to the image store with the given hash as the name, animated signatures are saved as GIFs
+func saveImage(hash string, sig util.Signature, format util.ImageFormat) error {
+	var buf bytes.Buffer
+	if err := util.Encode(&buf, sig, format); err != nil {
+		return err
 	}
+	return imageStore.Save(hash, buf.Bytes())
 }
 
-// Update the signature based on the image's last modification date
-func updateSignature(writer http.ResponseWriter, req util.SignatureRequest, generator generators.BaseGenerator) {
-	imagePath := fmt.Sprintf("%s/%s", vars.ImageRoot, req.Hash)
-	if stat, err := os.Stat(imagePath); err == nil {
-		modTime := stat.ModTime()
-		now := time.Now()
-		age := now.Sub(modTime)
+// Queue a background update of the signature based on the image's last modification date,
+// returns true if the image is outdated and its last update failed
+func updateSignature(req generators.SignatureRequest, generator generators.BaseGenerator, info storage.ImageInfo) bool {
+	age := time.Now().Sub(info.ModTime)
+	if age.Minutes() < vars.UpdateInterval {
+		return false
+	}
 
-		if age.Minutes() >= vars.UpdateInterval {
-			err = createAndSaveSignature(req, generator)
-			if err != nil {
-				writeTextResponse(writer, err.Error())
-				return
-			}
+	refreshPool.Submit(req.Hash, func() {
+		if err := createAndSaveSignature(req, generator); err != nil {
+			log.Println(err)
+			failedRefreshes.Store(req.Hash, true)
+		} else {
+			failedRefreshes.Delete(req.Hash)
 		}
-	}
+	})
+
+	_, failed := failedRefreshes.Load(req.Hash)
+	return failed
 }
 
-// Write text as a response to the client
-func writeTextResponse(writer http.ResponseWriter, text string) {
-	fmt.Fprintf(writer, text)
+// Render the error as a signature and send it with the matching status code
+func serveErrorSignature(writer http.ResponseWriter, generator generators.BaseGenerator, format util.ImageFormat, err error) {
+	log.Println(err)
+	status, message := util.ErrorStatus(err)
+	sig, err := generator.CreateErrorSignature(message)
+	if err != nil {
+		http.Error(writer, message, status)
+		return
+	}
+	writer.Header().Set("Content-Type", format.ContentType())
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.WriteHeader(status)
+	util.Encode(writer, sig, format)
 }
 
 // Show an existing signature
-func serveSignature(writer http.ResponseWriter, r *http.Request, req util.SignatureRequest, generator generators.BaseGenerator) {
+func serveSignature(writer http.ResponseWriter, r *http.Request, req generators.SignatureRequest, generator generators.BaseGenerator) {
 	attemptUpdate := true
 
 	// Check if an image already exists and create it if not
-	if _, err := os.Stat(fmt.Sprintf("%s/%s", vars.ImageRoot, req.Hash)); os.IsNotExist(err) {
+	info, err := imageStore.Stat(req.Hash)
+	if err == storage.ErrNotFound {
 		err = createAndSaveSignature(req, generator)
 		if err != nil {
-			writeTextResponse(writer, err.Error())
+			serveErrorSignature(writer, generator, req.Format, err)
 			return
 		}
 		attemptUpdate = false
+	} else if err != nil {
+		serveErrorSignature(writer, generator, req.Format, err)
+		return
 	}
 
-	if attemptUpdate {
-		updateSignature(writer, req, generator)
+	if attemptUpdate && updateSignature(req, generator, info) {
+		// The last good image is served, mark it as stale
+		writer.Header().Set("Warning", `110 - "Response is Stale"`)
+		writer.Header().Set("X-Signature-Stale", "true")
 	}
 
-	http.ServeFile(writer, r, fmt.Sprintf("%s/%s", vars.ImageRoot, req.Hash))
+	janitor.Touch(req.Hash)
+	serveImage(writer, r, req.Hash)
+}
+
+// Send the image with caching headers that expire when the image is due for its next refresh.
+// Conditional requests are answered with 304 Not Modified by http.ServeContent.
+func serveImage(writer http.ResponseWriter, r *http.Request, hash string) {
+	data, info, err := imageStore.Load(hash)
+	if err == storage.ErrNotFound {
+		http.NotFound(writer, r)
+		return
+	} else if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	modTime := info.ModTime
+	nextRefresh := modTime.Add(time.Duration(vars.UpdateInterval * float64(time.Minute)))
+	maxAge := int(time.Until(nextRefresh).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	header := writer.Header()
+	// The store only keeps the bytes, the type is told apart by the signature of the file
+	header.Set("Content-Type", util.DetectContentType(data))
+	header.Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(data)))
+	header.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+	http.ServeContent(writer, r, hash, modTime, bytes.NewReader(data))
 }
 
 // Front page
 func index(_ web.C, writer http.ResponseWriter, _ *http.Request) {
 	if err := indexTemplate.ExecuteWriter(pongo2.Context{
-		"skills":  util.SkillNames,
-		"has_aes": util.AesKey,
+		"games":   registry.Games(),
+		"has_aes": len(util.AesKey) > 0,
 	}, writer); err != nil {
 		http.Error(writer, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// Image cache counters, only mapped in debug mode
+func cacheStats(_ web.C, writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(cachedStore.Stats()); err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func registerGenerator(generator generators.BaseGenerator) {
 	goji.Get(generator.Url(), func(c web.C, writer http.ResponseWriter, request *http.Request) {
+		format, ext := parseFormat(c, writer, request, generator)
+		parseScale(c, request, generator)
 		parsedReq, err := generator.ParseSignatureRequest(c, request)
+		if err == nil && ext != "" {
+			if animated, ok := parsedReq.(generators.AnimatedRequest); ok && animated.Animated() {
+				err = fmt.Errorf("animated signatures are always GIFs, remove %s from the url", ext)
+			}
+		}
 		if err != nil {
-			writeTextResponse(writer, "Failed to parse the request: "+err.Error())
+			serveErrorSignature(writer, generator, format, fmt.Errorf("%w: %v", util.ErrInvalidRequest, err))
 			return
 		}
-		hash := finalizeHash(generator.Name(), generator.CreateHash(parsedReq))
-		req := util.SignatureRequest{Req: parsedReq, Hash: hash}
+		hash := finalizeHash(generator.Name(), parsedReq.Hash(), format)
+		req := generators.SignatureRequest{Req: parsedReq, Hash: hash, Format: format}
 
 		serveSignature(writer, request, req, generator)
 	})
 
-	formUrl := generator.FormUrl()
-	if formUrl != "" {
-		goji.Post(formUrl, func(c web.C, writer http.ResponseWriter, request *http.Request) {
-			generator.HandleForm(c, writer, request)
-		})
+	goji.Post(generators.FormUrl(generator), func(c web.C, writer http.ResponseWriter, request *http.Request) {
+		handleForm(writer, request, generator)
+	})
+	registry.Register(generator)
+}
+
+// Build the signature url from the front page form and show it if the generator accepts it
+func handleForm(writer http.ResponseWriter, request *http.Request, generator generators.BaseGenerator) {
+	if err := request.ParseForm(); err != nil {
+		util.ServeFormErrorPage(writer, "Invalid form data")
+		return
+	}
+	url, pathParams, err := generators.BuildUrl(generator, request.Form)
+	if err == nil {
+		err = generators.ValidateUrl(generator, url, pathParams)
+	}
+	if err != nil {
+		util.ServeFormErrorPage(writer, err.Error())
+		return
+	}
+	util.ServeResultPage(writer, url)
+}
+
+// Periodically remove abandoned images from the image store
+func collectImages(interval time.Duration) {
+	for range time.Tick(interval) {
+		report, err := janitor.Collect()
+		if err != nil {
+			log.Println("Failed to collect images:", err)
+			continue
+		}
+		log.Println("Collected images:", report)
+	}
+}
+
+// Periodically remove snapshots older than the history retention
+func pruneHistory(interval time.Duration) {
+	for range time.Tick(interval) {
+		removed, err := historyStore.Prune(time.Now())
+		if err != nil {
+			log.Println("Failed to prune history:", err)
+			continue
+		}
+		log.Printf("Pruned %d history snapshots", removed)
 	}
 }
 
-func finalizeHash(name, hash string) string {
-	return fmt.Sprintf("%s-%s", name, hash)
+// PNGs keep the hash of the generator while other formats are stored under their extension
+func finalizeHash(name, hash string, format util.ImageFormat) string {
+	if format == util.FormatPNG {
+		return fmt.Sprintf("%s-%s", name, hash)
+	}
+	return fmt.Sprintf("%s-%s.%s", name, hash, format)
+}
+
+// Read the format from the extension of the url, e.g. /:username/:skill/:goal.svg, and remove the extension
+// from the last url parameter. The extension is returned along with the format, without one the format is
+// negotiated from the Accept header.
+func parseFormat(c web.C, writer http.ResponseWriter, request *http.Request, generator generators.BaseGenerator) (util.ImageFormat, string) {
+	name := lastParam(generator)
+	value := c.URLParams[name]
+	ext := path.Ext(value)
+	if format, ok := util.FormatFromExtension(ext); ok {
+		c.URLParams[name] = strings.TrimSuffix(value, ext)
+		return format, ext
+	}
+	writer.Header().Add("Vary", "Accept")
+	return util.NegotiateFormat(request.Header.Get("Accept")), ""
+}
+
+// Read a scale suffix such as @2x from the last url parameter, e.g. /:username/:skill/:goal@2x.png, and pass it
+// on to the generator as the scale query parameter unless the query already has one. Only generators with
+// a scale parameter accept the suffix.
+func parseScale(c web.C, request *http.Request, generator generators.BaseGenerator) {
+	if !generator.Metadata().HasParam(util.ScaleParam) {
+		return
+	}
+	name := lastParam(generator)
+	value := c.URLParams[name]
+	at := strings.LastIndex(value, "@")
+	if at < 0 || !strings.HasSuffix(value, "x") {
+		return
+	}
+	suffix := value[at+1:]
+	if _, err := util.ParseScale(suffix); err != nil {
+		return
+	}
+	c.URLParams[name] = value[:at]
+	if request.URL.Query().Get(util.ScaleParam) != "" {
+		return
+	}
+	if request.URL.RawQuery != "" {
+		request.URL.RawQuery += "&"
+	}
+	request.URL.RawQuery += util.ScaleParam + "=" + suffix
+}
+
+// Name of the url parameter at the end of the generator's url, where the scale and format suffixes go
+func lastParam(generator generators.BaseGenerator) string {
+	pattern := generator.Url()
+	return strings.TrimPrefix(pattern[strings.LastIndex(pattern, "/")+1:], ":")
 }
 
 func main() {
@@ -177,13 +333,149 @@ func main() {
 	}
 	log.Printf("Using virtual host: %s", vars.VirtualHost)
 
-	if path := os.Getenv("IMG_PATH"); path != "" {
-		vars.ImageRoot = path
+	if store := os.Getenv("IMAGE_STORE"); store != "" {
+		vars.ImageStore = store
+	}
+	switch vars.ImageStore {
+	case "s3":
+		config := storage.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Prefix:    os.Getenv("S3_PREFIX"),
+		}
+		log.Printf("Using S3 image store: %s/%s", config.Endpoint, config.Bucket)
+		imageStore = storage.NewS3Store(config)
+	case "memory":
+		log.Println("Using in-memory image store")
+		imageStore = storage.NewMemoryStore()
+	default:
+		if path := os.Getenv("IMG_PATH"); path != "" {
+			vars.ImageRoot = path
+		}
+		log.Printf("Using image root: %s", vars.ImageRoot)
+		fileStore, err := storage.NewFileStore(vars.ImageRoot)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// Remove temporary files left behind by interrupted writes
+		if removed, err := fileStore.CleanTemp(); err != nil {
+			log.Println(err)
+		} else if removed > 0 {
+			log.Printf("Removed %d orphaned temporary images", removed)
+		}
+		imageStore = fileStore
+	}
+
+	if size := os.Getenv("IMAGE_CACHE_SIZE"); size != "" {
+		if s, err := strconv.ParseFloat(size, 64); err == nil {
+			vars.ImageCacheSize = s
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	if vars.ImageCacheSize > 0 {
+		log.Printf("Caching up to %.1f MB of images in memory", vars.ImageCacheSize)
+		cachedStore = storage.NewCachedStore(imageStore, int64(vars.ImageCacheSize*1024*1024))
+		imageStore = cachedStore
+	}
+
+	if retention := os.Getenv("IMAGE_RETENTION"); retention != "" {
+		if r, err := strconv.ParseFloat(retention, 64); err == nil {
+			vars.ImageRetention = r
+		} else {
+			log.Println(err.Error())
+		}
+	}
+
+	if quota := os.Getenv("IMAGE_QUOTA"); quota != "" {
+		if q, err := strconv.ParseFloat(quota, 64); err == nil {
+			vars.ImageQuota = q
+		} else {
+			log.Println(err.Error())
+		}
 	}
-	log.Printf("Using image root: %s", vars.ImageRoot)
-	if _, err := os.Stat(vars.ImageRoot); os.IsNotExist(err) {
-		os.MkdirAll(vars.ImageRoot, 0740)
+
+	if interval := os.Getenv("COLLECT_INTERVAL"); interval != "" {
+		if i, err := strconv.ParseFloat(interval, 64); err == nil {
+			vars.CollectInterval = i
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	log.Printf("Removing images not served in %.1f hours, quota %.1f MB", vars.ImageRetention, vars.ImageQuota)
+	janitor = storage.NewJanitor(imageStore, time.Duration(vars.ImageRetention*float64(time.Hour)),
+		int64(vars.ImageQuota*1024*1024))
+	// Refresh failures of removed images no longer matter
+	janitor.OnRemove = func(hash string) {
+		failedRefreshes.Delete(hash)
 	}
+	go collectImages(time.Duration(vars.CollectInterval * float64(time.Minute)))
+
+	if path, ok := os.LookupEnv("HISTORY_PATH"); ok {
+		vars.HistoryPath = path
+	}
+	if retention := os.Getenv("HISTORY_RETENTION"); retention != "" {
+		if r, err := strconv.ParseFloat(retention, 64); err == nil {
+			vars.HistoryRetention = r
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	if vars.HistoryPath != "" {
+		store, err := history.Open(vars.HistoryPath, time.Duration(vars.HistoryRetention*float64(24*time.Hour)))
+		if err != nil {
+			log.Fatalln("Failed to open the history database:", err)
+		}
+		log.Printf("Recording stats history to %s for %.1f days", vars.HistoryPath, vars.HistoryRetention)
+		historyStore = store
+		go pruneHistory(time.Duration(vars.CollectInterval * float64(time.Minute)))
+	}
+
+	if ttl := os.Getenv("STATS_TTL"); ttl != "" {
+		if t, err := strconv.ParseFloat(ttl, 64); err == nil {
+			vars.StatsCacheTTL = t
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	log.Printf("Caching stats for %.1f minutes", vars.StatsCacheTTL)
+
+	if timeout := os.Getenv("HISCORES_TIMEOUT"); timeout != "" {
+		if t, err := strconv.ParseFloat(timeout, 64); err == nil {
+			vars.HiscoresTimeout = t
+		} else {
+			log.Println(err.Error())
+		}
+	}
+
+	if retries := os.Getenv("HISCORES_RETRIES"); retries != "" {
+		if r, err := strconv.Atoi(retries); err == nil {
+			vars.HiscoresRetries = r
+		} else {
+			log.Println(err.Error())
+		}
+	}
+
+	if workers := os.Getenv("REFRESH_WORKERS"); workers != "" {
+		if w, err := strconv.Atoi(workers); err == nil {
+			vars.RefreshWorkers = w
+		} else {
+			log.Println(err.Error())
+		}
+	}
+
+	if queue := os.Getenv("REFRESH_QUEUE"); queue != "" {
+		if q, err := strconv.Atoi(queue); err == nil {
+			vars.RefreshQueue = q
+		} else {
+			log.Println(err.Error())
+		}
+	}
+	log.Printf("Refreshing signatures with %d workers, queue size %d", vars.RefreshWorkers, vars.RefreshQueue)
+	refreshPool = refresh.NewPool(vars.RefreshWorkers, vars.RefreshQueue)
 
 	if key := os.Getenv("AES_KEY"); key != "" {
 		util.AesKey = []byte(key)
@@ -205,7 +497,8 @@ func main() {
 	http.Handle("/assets/", static)
 
 	profile := os.Getenv("ENABLE_DEBUG")
-	if profile == "1" || profile == "true" {
+	debug := profile == "1" || profile == "true"
+	if debug {
 		log.Println("Mapping debug routes...")
 		goji.Handle("/debug/pprof/", pprof.Index)
 		goji.Handle("/debug/pprof/cmdline", pprof.Cmdline)
@@ -215,13 +508,37 @@ func main() {
 		goji.Handle("/debug/pprof/heap", pprof.Handler("heap").ServeHTTP)
 		goji.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine").ServeHTTP)
 		goji.Handle("/debug/pprof/threadcreate", pprof.Handler("threadcreate").ServeHTTP)
+		if cachedStore != nil {
+			goji.Get("/debug/cache", cacheStats)
+		}
 	}
 
 	// Generators
 	log.Println("Registering generators...")
-	registerGenerator(new(rs3.BoxGoalGenerator))
-	registerGenerator(new(multi.MultiGoalGenerator))
-	//registerGenerator(new(rs3.ExampleGenerator))
+	// All generators share the same cache so a player's stats are only fetched once for all of their signatures
+	var hiscores util.StatsProvider = util.NewHiscoresProvider(time.Duration(vars.HiscoresTimeout*float64(time.Second)), vars.HiscoresRetries)
+	if historyStore != nil {
+		// Record below the cache so every request to the hiscores is recorded once
+		hiscores = history.NewRecordingProvider(hiscores, historyStore)
+	}
+	stats := util.NewStatsCache(hiscores, time.Duration(vars.StatsCacheTTL*float64(time.Minute)))
+	// OSRS routes are more specific and have to be mapped before the RS3 ones
+	for _, game := range []*util.Game{util.OSRSGame, util.RS3Game} {
+		box := rs3.NewBoxGoalGenerator(game, stats, historyStore)
+		registerGenerator(generators.Wrap[rs3.BoxGoalRequest](box))
+		goji.Get(game.Route("/api/goal/:username/:skill/:goal"), api.GoalHandler(box))
+		registerGenerator(generators.Wrap[multi.MultiGoalRequest](multi.NewMultiGoalGenerator(game, stats, historyStore)))
+		registerGenerator(generators.Wrap[rs3.TotalRequest](rs3.NewTotalGenerator(game, stats)))
+		registerGenerator(generators.Wrap[rs3.MaxCapeRequest](rs3.NewMaxCapeGenerator(game, stats)))
+		registerGenerator(generators.Wrap[grid.GridRequest](grid.NewGridGenerator(game, stats)))
+		if historyStore != nil {
+			registerGenerator(generators.Wrap[multi.GainsRequest](multi.NewGainsGenerator(game, stats, historyStore)))
+		}
+	}
+	if debug {
+		// The example generator is only a template for new generators
+		registerGenerator(generators.Wrap[rs3.ExampleRequest](rs3.NewExampleGenerator()))
+	}
 
 	// Serve
 	goji.Serve()